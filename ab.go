@@ -0,0 +1,70 @@
+package mux
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+)
+
+// How long a client keeps the variant it was assigned
+const variantMaxAge = 30 * 24 * 60 * 60
+
+/**
+@info Adds a GET route splitting its traffic between two handlers, for experiments. Each client is
+assigned a variant once and keeps it through a cookie, and the handlers read it with Variant
+@param {string} [path] The route path
+@param {Handler} [a] The handler of the A variant
+@param {Handler} [b] The handler of the B variant
+@param {float64} [weight] The share of clients sent to B, from 0 to 1
+@returns {*RouteBuilder}
+*/
+func (r *Router) GetAB(path string, a Handler, b Handler, weight float64) *RouteBuilder {
+	if weight < 0 || weight > 1 {
+		panic(fmt.Sprintf("Minima: GetAB %s needs a weight between 0 and 1, got %v", path, weight))
+	}
+	// One cookie per experiment, cookie names can't hold the / of paths
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	cookie := fmt.Sprintf("mux_ab_%08x", h.Sum32())
+
+	return r.Get(path, func(w http.ResponseWriter, req *http.Request) {
+		variant := ""
+		if c, err := req.Cookie(cookie); err == nil && (c.Value == "A" || c.Value == "B") {
+			variant = c.Value
+		} else {
+			variant = "A"
+			if rand.Float64() < weight {
+				variant = "B"
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookie,
+				Value:    variant,
+				Path:     "/",
+				MaxAge:   variantMaxAge,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		if rc := getRouteContext(req); rc != nil {
+			rc.variant = variant
+		}
+		if variant == "B" {
+			b(w, req)
+		} else {
+			a(w, req)
+		}
+	})
+}
+
+/**
+@info Gets the variant of the GetAB route serving the request
+@param {*http.Request} [r] The net/http request instance
+@returns {string} A or B, empty outside a GetAB route
+*/
+func Variant(r *http.Request) string {
+	if rc := getRouteContext(r); rc != nil {
+		return rc.variant
+	}
+	return ""
+}
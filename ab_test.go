@@ -0,0 +1,46 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetAB(t *testing.T) {
+	rt := NewRouter()
+	rt.GetAB("/home", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a " + Variant(r)))
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("b " + Variant(r)))
+	}, 0.1)
+
+	counts := map[string]int{}
+	var cookie *http.Cookie
+	for i := 0; i < 10000; i++ {
+		w := serve(rt, http.MethodGet, "/home")
+		counts[w.Body.String()]++
+		if cookies := w.Result().Cookies(); len(cookies) != 1 {
+			t.Fatalf("expected the variant cookie, got %v", cookies)
+		} else if cookies[0].Value == "B" {
+			cookie = cookies[0]
+		}
+	}
+	if counts["a A"]+counts["b B"] != 10000 || counts["b B"] < 800 || counts["b B"] > 1200 {
+		t.Errorf("expected about 10%% of the requests on B, got %v", counts)
+	}
+
+	// A client keeps its variant, without being assigned a new one
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/home", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if w.Body.String() != "b B" || w.Header().Get("Set-Cookie") != "" {
+			t.Fatalf("sticky request %d = %q with Set-Cookie %q, want b B without one", i, w.Body.String(), w.Header().Get("Set-Cookie"))
+		}
+	}
+
+	if got := Variant(httptest.NewRequest(http.MethodGet, "/", nil)); got != "" {
+		t.Errorf("expected no variant outside a GetAB route, got %q", got)
+	}
+}
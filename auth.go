@@ -0,0 +1,90 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// The authenticated user stored in the request context by Auth
+type User interface {
+	HasRole(role string) bool
+}
+
+// The unexported key the authenticated user is stored under in the request context
+type userKey struct{}
+
+/**
+@info Creates a middleware authenticating requests with a bearer token, answering 401 when
+the token is missing or rejected and storing the user in the request context otherwise
+@param {func(token string) (User, error)} [validate] The function resolving a token into a user
+@returns {func(http.Handler) http.Handler}
+*/
+func Auth(validate func(token string) (User, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			AddVary(w, "Authorization")
+			token, ok := bearerToken(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			user, err := validate(token)
+			if err != nil || user == nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+		})
+	}
+}
+
+/**
+@info Creates a middleware only letting through users with the given role, it has to run after Auth.
+Anonymous requests get 401 and users without the role get 403
+@param {string} [role] The required role
+@returns {func(http.Handler) http.Handler}
+*/
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUser(r)
+			if user == nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !user.HasRole(role) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+/**
+@info Gets the user authenticated by the Auth middleware
+@param {*http.Request} [r] The net/http request instance
+@returns {User} nil for anonymous requests
+*/
+func GetUser(r *http.Request) User {
+	user, _ := r.Context().Value(userKey{}).(User)
+	return user
+}
+
+/**
+@info Extracts the token from a bearer Authorization header
+@param {*http.Request} [r] The net/http request instance
+@returns {string, bool}
+*/
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(auth[7:])
+	return token, token != ""
+}
@@ -0,0 +1,75 @@
+package mux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testUser []string
+
+func (u testUser) HasRole(role string) bool {
+	for _, r := range u {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func testAuth(token string) (User, error) {
+	switch token {
+	case "admin":
+		return testUser{"admin"}, nil
+	case "guest":
+		return testUser{}, nil
+	}
+	return nil, errors.New("unknown token")
+}
+
+func TestAuth(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/public", write("public"))
+	rt.Get("/me", func(w http.ResponseWriter, r *http.Request) {
+		if GetUser(r) == nil {
+			t.Error("expected user in context")
+		}
+		w.Write([]byte("me"))
+	}).Middleware(Auth(testAuth))
+	rt.Get("/admin", write("admin")).Middleware(Auth(testAuth), RequireRole("admin"))
+
+	tests := []struct {
+		path, token string
+		code        int
+	}{
+		{"/public", "", http.StatusOK},
+		{"/me", "", http.StatusUnauthorized},
+		{"/me", "bogus", http.StatusUnauthorized},
+		{"/me", "guest", http.StatusOK},
+		{"/admin", "guest", http.StatusForbidden},
+		{"/admin", "admin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s with token %q: expected %d, got %d", tt.path, tt.token, tt.code, w.Code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s with token %q: expected WWW-Authenticate header", tt.path, tt.token)
+		}
+	}
+}
+
+func TestRequireRoleWithoutAuth(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/admin", write("admin")).Middleware(RequireRole("admin"))
+	if w := serve(rt, http.MethodGet, "/admin"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
@@ -0,0 +1,93 @@
+package mux
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+/**
+@info Wraps a typed handler into a Handler decoding the JSON request body into T, answering with a
+ValidationError when the body doesn't decode
+@param {func(http.ResponseWriter, *http.Request, T)} [h] The typed handler
+@returns {Handler}
+*/
+func Bind[T any](h func(w http.ResponseWriter, r *http.Request, body T)) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body T
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			WriteValidationError(w, r, bodyValidationError(err))
+			return
+		}
+		h(w, r, body)
+	}
+}
+
+/**
+ * @info The options of RequireJSON
+ * @property {bool} [ValidateBody] Reads the body ahead of the handler and answers 400 when it isn't valid JSON
+ */
+type JSONOptions struct {
+	ValidateBody bool
+}
+
+/**
+@info Creates a middleware answering 415 to the requests on unsafe methods whose body isn't declared as
+JSON, application/json or an application/*+json type with any parameters. Requests without a body pass
+@param {...JSONOptions} [opts] The options, the body isn't validated by default
+@returns {func(http.Handler) http.Handler}
+*/
+func RequireJSON(opts ...JSONOptions) func(http.Handler) http.Handler {
+	var o JSONOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength == 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !isJSON(r.Header.Get("Content-Type")) {
+				http.Error(w, "Unsupported media type, expected application/json", http.StatusUnsupportedMediaType)
+				return
+			}
+			if o.ValidateBody {
+				body, err := io.ReadAll(r.Body)
+				r.Body.Close()
+				if err != nil {
+					http.Error(w, "Malformed request body", http.StatusBadRequest)
+					return
+				}
+				if !json.Valid(body) {
+					WriteValidationError(w, r, bodyValidationError(errors.New("malformed JSON")))
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+/**
+@info Reports whether a Content-Type header declares JSON
+@param {string} [contentType] The header value
+@returns {bool}
+*/
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,83 @@
+package mux
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type createUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestBind(t *testing.T) {
+	rt := NewRouter()
+	rt.Post("/users", Bind(func(w http.ResponseWriter, r *http.Request, body createUser) {
+		fmt.Fprintf(w, "%s is %d", body.Name, body.Age)
+	}))
+
+	tests := []struct {
+		body string
+		code int
+		want string
+	}{
+		{`{"name":"ada","age":36}`, http.StatusOK, "ada is 36"},
+		{`{"name":"ada","age":"old"}`, http.StatusBadRequest, `{"error":"Validation failed","fields":[{"in":"body","field":"age","message":"expected int"}]}` + "\n"},
+		{`{"name":`, http.StatusBadRequest, `{"error":"Validation failed","fields":[{"in":"body","message":"malformed JSON"}]}` + "\n"},
+		{``, http.StatusBadRequest, `{"error":"Validation failed","fields":[{"in":"body","message":"required"}]}` + "\n"},
+	}
+	for _, tt := range tests {
+		w := rt.TestRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+		if w.Code != tt.code || w.Body.String() != tt.want {
+			t.Errorf("body %q: got %d %q, want %d %q", tt.body, w.Code, w.Body.String(), tt.code, tt.want)
+		}
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}
+	rt := NewRouter()
+	rt.Post("/strict", echo).Middleware(RequireJSON(JSONOptions{ValidateBody: true}))
+	rt.Post("/loose", echo).Middleware(RequireJSON())
+	rt.Get("/strict", write("get")).Middleware(RequireJSON())
+
+	tests := []struct {
+		method, path, contentType, body string
+		code                            int
+	}{
+		{http.MethodPost, "/strict", "application/json", `{"a":1}`, http.StatusOK},
+		{http.MethodPost, "/strict", "application/json; charset=utf-8", `{"a":1}`, http.StatusOK},
+		{http.MethodPost, "/strict", "application/merge-patch+json", `{"a":1}`, http.StatusOK},
+		{http.MethodPost, "/strict", "", `{"a":1}`, http.StatusUnsupportedMediaType},
+		{http.MethodPost, "/strict", "text/plain", `{"a":1}`, http.StatusUnsupportedMediaType},
+		{http.MethodPost, "/strict", "application/json", `{"a":`, http.StatusBadRequest},
+		{http.MethodPost, "/loose", "application/json", `{"a":`, http.StatusOK},
+		{http.MethodPost, "/strict", "", "", http.StatusOK},
+		{http.MethodGet, "/strict", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		var body io.Reader
+		if tt.body != "" {
+			body = strings.NewReader(tt.body)
+		}
+		req := httptest.NewRequest(tt.method, tt.path, body)
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s %s with %q %q = %d, want %d", tt.method, tt.path, tt.contentType, tt.body, w.Code, tt.code)
+		}
+		if tt.code == http.StatusOK && tt.method == http.MethodPost && w.Body.String() != tt.body {
+			t.Errorf("%s %s: handler read %q, want %q", tt.method, tt.path, w.Body.String(), tt.body)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package mux
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+/**
+@info Reads the request body once and keeps it on the request context, so middleware and the handler
+can all read it. Every call rewinds r.Body to the start of the buffered bytes. The body is read through
+the router's MaxBodyBytes limit, a body past it fails without being buffered and can't be read again
+@param {*http.Request} [r] The net/http request instance
+@returns {[]byte, error} An empty slice for requests without a body
+*/
+func BufferBody(r *http.Request) ([]byte, error) {
+	rc := getRouteContext(r)
+	if rc != nil && rc.body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(rc.body))
+		return rc.body, nil
+	}
+
+	body := []byte{}
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rc != nil {
+		rc.body = body
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,65 @@
+package mux
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBufferBody(t *testing.T) {
+	var seen []string
+	reader := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := BufferBody(r)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+					return
+				}
+				seen = append(seen, name+":"+string(body))
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	rt := NewRouter()
+	rt.MaxBodyBytes = 16
+	rt.UseRaw(reader("log"))
+	rt.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s", body)
+	}).Middleware(reader("validate"))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("POST", "/echo", strings.NewReader("hello")))
+	if got := w.Body.String(); got != "hello" {
+		t.Errorf("handler read %q, want %q", got, "hello")
+	}
+	if want := "log:hello validate:hello"; strings.Join(seen, " ") != want {
+		t.Errorf("middleware read %q, want %q", seen, want)
+	}
+
+	seen = nil
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("POST", "/echo", strings.NewReader(strings.Repeat("x", 17))))
+	if w.Code != http.StatusRequestEntityTooLarge || seen != nil {
+		t.Errorf("declared oversized body answered %d after %q, want %d", w.Code, seen, http.StatusRequestEntityTooLarge)
+	}
+
+	// Without a declared length the limit is hit while buffering
+	req := httptest.NewRequest("POST", "/echo", io.MultiReader(strings.NewReader(strings.Repeat("x", 17))))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge || seen != nil {
+		t.Errorf("streamed oversized body answered %d after %q, want %d", w.Code, seen, http.StatusRequestEntityTooLarge)
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("POST", "/echo", nil))
+	if w.Code != http.StatusOK || strings.Join(seen, " ") != "log: validate:" {
+		t.Errorf("empty body answered %d after %q", w.Code, seen)
+	}
+}
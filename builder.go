@@ -0,0 +1,220 @@
+package mux
+
+import (
+	"net/http"
+	"time"
+)
+
+/**
+ * @info The route a verb method just registered, carrying the route modifiers. It embeds the router
+ * so registrations keep chaining, rt.Get(...).Name("a").Post(...) modifies the GET route and registers a POST one
+ * @property {*Router} [Router] The router the route is registered on
+ * @property {*Route} [route] The registered route
+ */
+type RouteBuilder struct {
+	*Router
+	route *Route
+}
+
+/**
+@info Ends the route modifiers, going back to the router
+@returns {*Router}
+*/
+func (b *RouteBuilder) End() *Router {
+	return b.Router
+}
+
+/**
+@info Applies a route modifier under the router lock, since the route may already be serving requests
+@param {func(*Route)} [fn] The modifier
+*/
+func (b *RouteBuilder) modify(fn func(*Route)) {
+	b.Router.mu.Lock()
+	defer b.Router.mu.Unlock()
+	b.Router.mustNotBeFrozen("a route modifier")
+	fn(b.route)
+	b.Router.invalidateChains()
+}
+
+/**
+@info Validates a param of the route, failing values let later routes match instead
+@param {string} [name] The param name to validate
+@param {func(string) bool} [validate] The callback deciding whether the value is acceptable
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) Validate(name string, validate func(string) bool) *RouteBuilder {
+	b.modify(func(route *Route) { route.Validate(name, validate) })
+	return b
+}
+
+/**
+@info Lets the last param of the route absorb excess path segments,
+routes are strict by default and miss when the request has more segments than the route
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) Greedy() *RouteBuilder {
+	b.modify(func(route *Route) { route.Greedy() })
+	return b
+}
+
+/**
+@info Declares query params the route requires, the router answers 400 when one is missing
+@param {...string} [names] The query param names
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) Queries(names ...string) *RouteBuilder {
+	b.modify(func(route *Route) { route.Queries(names...) })
+	return b
+}
+
+/**
+@info Wraps the route handler with the named middleware stacks, resolved when the route matches
+@param {...string} [names] The stack names defined with DefineStack
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) UseStack(names ...string) *RouteBuilder {
+	b.modify(func(route *Route) { route.UseStack(names...) })
+	return b
+}
+
+/**
+@info Limits each client IP to n requests per window on the route, answering 429 past that
+@param {int} [n] The requests allowed per window
+@param {time.Duration} [per] The window length
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) RateLimit(n int, per time.Duration) *RouteBuilder {
+	b.modify(func(route *Route) { route.RateLimit(n, per) })
+	return b
+}
+
+/**
+@info Runs at most n requests of the route at once, answering 503 past that
+@param {int} [n] The requests allowed at once
+@param {...ConcurrencyOptions} [opts] The options, set Wait to queue the requests past the limit for a while
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) MaxConcurrent(n int, opts ...ConcurrencyOptions) *RouteBuilder {
+	b.modify(func(route *Route) { route.MaxConcurrent(n, opts...) })
+	return b
+}
+
+/**
+@info Wraps the route handler with middleware that only runs for it
+@param {...func(http.Handler)http.Handler} [mw] The middleware stack to append
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) Middleware(mw ...func(http.Handler) http.Handler) *RouteBuilder {
+	b.modify(func(route *Route) { route.Middleware(mw...) })
+	return b
+}
+
+/**
+@info Sets the status the route responds with when the handler doesn't write one
+@param {int} [status] The default status code, like 204
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) Status(status int) *RouteBuilder {
+	b.modify(func(route *Route) { route.Status(status) })
+	return b
+}
+
+/**
+@info Sets the Cache-Control header on the route responses, the handler can still override it
+@param {string} [directive] The cache directive, like public, max-age=86400
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) CacheControl(directive string) *RouteBuilder {
+	b.modify(func(route *Route) { route.CacheControl(directive) })
+	return b
+}
+
+/**
+@info Marks the route as deprecated
+@param {time.Time} [sunset] The date the route goes away, zero leaves the Sunset header out
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) Deprecated(sunset time.Time) *RouteBuilder {
+	b.modify(func(route *Route) { route.Deprecated(sunset) })
+	return b
+}
+
+/**
+@info Adds a function the request has to satisfy for the route to match
+@param {func(*http.Request) bool} [match] The function deciding whether the request matches
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) MatchFunc(match func(*http.Request) bool) *RouteBuilder {
+	b.modify(func(route *Route) { route.MatchFunc(match) })
+	return b
+}
+
+/**
+@info Restricts the route to requests for the given host
+@param {string} [host] The hostname, the request port is ignored
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) Host(host string) *RouteBuilder {
+	b.modify(func(route *Route) { route.Host(host) })
+	return b
+}
+
+/**
+@info Names the route
+@param {string} [name] The route name
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) Name(name string) *RouteBuilder {
+	b.modify(func(route *Route) { route.Name(name) })
+	return b
+}
+
+/**
+@info Marks the route as a health check, it keeps being served while the router is draining
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) HealthCheck() *RouteBuilder {
+	b.modify(func(route *Route) { route.HealthCheck() })
+	return b
+}
+
+/**
+@info Declares an optional query param of the route, read with Query, that falls back to a default
+@param {string} [name] The query param name
+@param {string} [def] The default value
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) QueryDefault(name string, def string) *RouteBuilder {
+	b.modify(func(route *Route) { route.QueryDefault(name, def) })
+	return b
+}
+
+/**
+@info Sets the CORS policy of the route, applied to its responses and to the preflight requests for it
+@param {CORSOptions} [opts] The policy
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) CORS(opts CORSOptions) *RouteBuilder {
+	b.modify(func(route *Route) { route.CORS(opts) })
+	return b
+}
+
+/**
+@info Tags the route, for filtering the listings with Routes and Walk
+@param {...string} [tags] The tags, like public or v1
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) Tag(tags ...string) *RouteBuilder {
+	b.modify(func(route *Route) { route.Tag(tags...) })
+	return b
+}
+
+/**
+@info Routes requests by file extension, /data with Format("json", "xml") serves /data.json and /data.xml
+@param {...string} [exts] The extensions without the dot
+@returns {*RouteBuilder}
+*/
+func (b *RouteBuilder) Format(exts ...string) *RouteBuilder {
+	b.modify(func(route *Route) { route.Format(exts...) })
+	return b
+}
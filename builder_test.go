@@ -0,0 +1,28 @@
+package mux
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRouteBuilder(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users", write("users")).Status(http.StatusAccepted).
+		Post("/users", write("")).Status(http.StatusCreated).
+		End().Get("/health", write("ok"))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, "/users", http.StatusAccepted},
+		{http.MethodPost, "/users", http.StatusCreated},
+		{http.MethodGet, "/health", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := serve(rt, tt.method, tt.path); w.Code != tt.code {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.code)
+		}
+	}
+}
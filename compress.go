@@ -0,0 +1,195 @@
+package mux
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A content encoding the Compress middleware can use
+type encoder struct {
+	name    string
+	factory func(w io.Writer) io.WriteCloser
+}
+
+var (
+	// The registered encoders, most preferred first
+	encoders = []encoder{{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }}}
+	// Guards the encoders
+	encodersMu sync.RWMutex
+)
+
+/**
+@info Registers a content encoding for the Compress middleware, like a Brotli one under br. It's
+preferred over the ones registered before when the client accepts several with the same quality,
+registering a name again replaces its factory
+@param {string} [name] The Content-Encoding token
+@param {func(w io.Writer) io.WriteCloser} [factory] Makes an encoder writing to w, closing it flushes the rest
+*/
+func RegisterEncoder(name string, factory func(w io.Writer) io.WriteCloser) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	for i, e := range encoders {
+		if strings.EqualFold(e.name, name) {
+			encoders = append(encoders[:i], encoders[i+1:]...)
+			break
+		}
+	}
+	encoders = append([]encoder{{name, factory}}, encoders...)
+}
+
+/**
+@info Picks the registered encoding the client accepts with the highest quality
+@param {string} [accept] The Accept-Encoding header
+@returns {encoder, bool} false when the client accepts none of them
+*/
+func negotiateEncoding(accept string) (encoder, bool) {
+	qualities := parseQualities(accept)
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	var best encoder
+	bestQ := 0.0
+	for _, e := range encoders {
+		q, ok := qualities[strings.ToLower(e.name)]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+/**
+@info Creates a middleware compressing responses with the best registered encoding the client accepts,
+gzip is built in and others are added with RegisterEncoder. Responses that already have a
+Content-Encoding, or no body, are sent as is
+@returns {func(http.Handler) http.Handler}
+*/
+func Compress() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			AddVary(w, "Accept-Encoding")
+			enc, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if !ok || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, encoding: enc}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// The response writer wrapper encoding the body
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    encoder
+	enc         io.WriteCloser
+	wroteHeader bool
+}
+
+/**
+@info Sends the response header, switching to the encoding unless the response can't be encoded
+@param {int} [status] The response status code
+*/
+func (w *compressWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if h.Get("Content-Encoding") == "" && status != http.StatusNoContent && status != http.StatusNotModified && status >= http.StatusOK {
+		h.Set("Content-Encoding", w.encoding.name)
+		h.Del("Content-Length")
+		w.enc = w.encoding.factory(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+/**
+@info Writes the response body through the encoder
+@param {[]byte} [b] The body bytes
+@returns {int, error}
+*/
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		// net/http would sniff the encoded bytes, so the type is detected on the plain ones
+		if _, ok := w.Header()["Content-Type"]; !ok {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.enc.Write(b)
+}
+
+/**
+@info Flushes the encoded bytes so far and the wrapped response instance
+*/
+func (w *compressWriter) Flush() {
+	w.FlushError()
+}
+
+/**
+@info Flushes the encoded bytes so far and the wrapped response instance
+@returns {error} http.ErrNotSupported when the wrapped response instance can't flush
+*/
+func (w *compressWriter) FlushError() error {
+	if !canFlush(w.ResponseWriter) {
+		return http.ErrNotSupported
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return flush(w.ResponseWriter)
+}
+
+/**
+@info Reports whether the wrapped response instance can flush
+@returns {bool}
+*/
+func (w *compressWriter) canFlush() bool {
+	return canFlush(w.ResponseWriter)
+}
+
+/**
+@info Hijacks the wrapped response instance connection when it supports it
+@returns {net.Conn, *bufio.ReadWriter, error}
+*/
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+/**
+@info Gets the wrapped response instance
+@returns {http.ResponseWriter}
+*/
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+/**
+@info Finishes the encoded body
+*/
+func (w *compressWriter) close() {
+	if w.enc != nil {
+		w.enc.Close()
+	}
+}
@@ -0,0 +1,82 @@
+package mux
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A stand-in for a Brotli encoder, it marks the body instead of encoding it
+type fakeBrotli struct{ w io.Writer }
+
+func (f fakeBrotli) Write(b []byte) (int, error) {
+	return f.w.Write([]byte(strings.ToUpper(string(b))))
+}
+
+func (f fakeBrotli) Close() error { return nil }
+
+func compressRequest(rt *Router, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", accept)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompress(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/", write("hello")).Middleware(Compress())
+
+	w := compressRequest(rt, "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected a gzip response, got %v", w.Header())
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("expected the type of the plain body, got %q", got)
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != "hello" {
+		t.Errorf("expected the gzipped body, got %q", body)
+	}
+
+	if w := compressRequest(rt, "br"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != "hello" {
+		t.Errorf("expected an identity response without a br encoder, got %v %q", w.Header(), w.Body.String())
+	}
+	if w := compressRequest(rt, "gzip;q=0"); w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("expected gzip;q=0 to disable compression, got %v", w.Header())
+	}
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	RegisterEncoder("br", func(w io.Writer) io.WriteCloser { return fakeBrotli{w} })
+	defer func() {
+		encodersMu.Lock()
+		encoders = encoders[1:]
+		encodersMu.Unlock()
+	}()
+
+	rt := NewRouter()
+	rt.Get("/", write("hello")).Middleware(Compress())
+	tests := []struct{ accept, encoding string }{
+		{"br, gzip", "br"},
+		{"gzip, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"*", "br"},
+		{"deflate", ""},
+	}
+	for _, tt := range tests {
+		w := compressRequest(rt, tt.accept)
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("Accept-Encoding %q: expected %q, got %q", tt.accept, tt.encoding, got)
+		}
+		if tt.encoding == "br" && w.Body.String() != "HELLO" {
+			t.Errorf("Accept-Encoding %q: expected the br body, got %q", tt.accept, w.Body.String())
+		}
+	}
+}
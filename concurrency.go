@@ -0,0 +1,72 @@
+package mux
+
+import (
+	"net/http"
+	"time"
+)
+
+/**
+ * @info The options of MaxConcurrent
+ * @property {time.Duration} [Wait] How long a request past the limit waits for a slot before getting 503, 0 rejects it right away
+ */
+type ConcurrencyOptions struct {
+	Wait time.Duration
+}
+
+/**
+@info Creates a middleware running at most n requests at once, the others get 503 with a Retry-After header.
+The slot is released when the handler returns or panics. Each call makes its own semaphore, so wrapping
+several routes with the same middleware value makes them share the limit
+@param {int} [n] The requests allowed at once
+@param {...ConcurrencyOptions} [opts] The options, requests past the limit are rejected right away by default
+@returns {func(http.Handler) http.Handler}
+*/
+func MaxConcurrent(n int, opts ...ConcurrencyOptions) func(http.Handler) http.Handler {
+	if n <= 0 {
+		panic("Minima: MaxConcurrent needs a positive limit")
+	}
+	var o ConcurrencyOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	slots := make(chan struct{}, n)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acquire(r, slots, o.Wait) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many concurrent requests", http.StatusServiceUnavailable)
+				return
+			}
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+/**
+@info Takes a slot of a semaphore, waiting for one up to a timeout or until the request is canceled
+@param {*http.Request} [r] The net/http request instance
+@param {chan struct{}} [slots] The semaphore
+@param {time.Duration} [wait] How long to wait, 0 doesn't wait
+@returns {bool} Whether the slot was taken
+*/
+func acquire(r *http.Request, slots chan struct{}, wait time.Duration) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+	if wait <= 0 {
+		return false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
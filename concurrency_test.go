@@ -0,0 +1,80 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrent(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	rt := NewRouter()
+	rt.Post("/report", func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.Write([]byte("report"))
+	}).MaxConcurrent(2)
+	rt.Post("/queued", func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.Write([]byte("queued"))
+	}).MaxConcurrent(1, ConcurrencyOptions{Wait: 5 * time.Second})
+	rt.Post("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}).MaxConcurrent(1)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 3)
+	post := func(path string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(rt, "POST", path).Code
+		}()
+	}
+
+	post("/report")
+	post("/report")
+	<-entered
+	<-entered
+	if w := serve(rt, "POST", "/report"); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("request past the limit answered %d with Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	release <- struct{}{}
+	release <- struct{}{}
+	wg.Wait()
+	for i := 0; i < 2; i++ {
+		if code := <-codes; code != http.StatusOK {
+			t.Errorf("request within the limit answered %d", code)
+		}
+	}
+
+	// The second request waits for the slot instead of failing
+	post("/queued")
+	<-entered
+	post("/queued")
+	release <- struct{}{}
+	<-entered
+	release <- struct{}{}
+	wg.Wait()
+	for i := 0; i < 2; i++ {
+		if code := <-codes; code != http.StatusOK {
+			t.Errorf("queued request answered %d", code)
+		}
+	}
+
+	// A panicking handler gives its slot back
+	for i := 0; i < 2; i++ {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected the handler panic")
+				}
+			}()
+			rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/panic", nil))
+		}()
+	}
+}
@@ -1 +1,201 @@
 package mux
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// The unexported key the router state is stored under in the request context, no other package can
+// read or overwrite it
+type contextKey struct{}
+
+/**
+ * @info The per request router state, it's also the request context so attaching it costs a single allocation
+ * @property {context.Context} [Context] The request context the state is attached to
+ * @property {bool} [matched] Whether a route matched the request
+ * @property {map[string]string} [params] The path params of the matched route
+ * @property {map[string][]string} [query] The query params the matched route declared with Queries or QueryDefault
+ * @property {http.Handler} [next] The handler the running middleware stack ends in
+ * @property {*Route} [route] The matched route
+ * @property {time.Duration} [matchDuration] How long looking the route up took
+ * @property {string} [variant] The variant of the GetAB route serving the request
+ * @property {*Router} [owner] The router the state belongs to, nil until a router serves the request
+ * @property {[]byte} [body] The request body buffered by BufferBody, nil until it's called
+ * @property {string} [format] The extension the matched route serves, for routes using Format
+ */
+type routeContext struct {
+	context.Context
+	matched       bool
+	params        map[string]string
+	query         map[string][]string
+	next          http.Handler
+	route         *Route
+	matchDuration time.Duration
+	variant       string
+	owner         *Router
+	body          []byte
+	format        string
+}
+
+/**
+@info Gets a value from the request context, the router state under its own key
+@param {interface{}} [key] The value key
+@returns {interface{}}
+*/
+func (rc *routeContext) Value(key interface{}) interface{} {
+	if key == (contextKey{}) {
+		return rc
+	}
+	return rc.Context.Value(key)
+}
+
+/**
+@info Gets the router state from the request context
+@param {*http.Request} [r] The net/http request instance
+@returns {*routeContext} nil when the request never went through a router
+*/
+func getRouteContext(r *http.Request) *routeContext {
+	rc, _ := r.Context().Value(contextKey{}).(*routeContext)
+	return rc
+}
+
+/**
+@info Attaches router state to the request, reusing the one already there
+@param {*http.Request} [r] The net/http request instance
+@returns {*http.Request, *routeContext}
+*/
+func withRouteContext(r *http.Request) (*http.Request, *routeContext) {
+	if rc := getRouteContext(r); rc != nil {
+		return r, rc
+	}
+	rc := &routeContext{Context: r.Context()}
+	return r.WithContext(rc), rc
+}
+
+/**
+@info Attaches the state of a router to the request. A router mounted as the handler of another one gets
+state of its own, so the params of the inner router don't overwrite the outer ones and the inner handlers
+only see their own
+@param {*http.Request} [req] The net/http request instance
+@param {*Router} [owner] The router serving the request
+@returns {*http.Request, *routeContext}
+*/
+func withRouterContext(req *http.Request, owner *Router) (*http.Request, *routeContext) {
+	if rc := getRouteContext(req); rc != nil && (rc.owner == nil || rc.owner == owner) {
+		rc.owner = owner
+		return req, rc
+	}
+	rc := &routeContext{Context: req.Context(), owner: owner}
+	return req.WithContext(rc), rc
+}
+
+/**
+@info Prepares a request so middleware wrapping the router can read Matched after serving it
+@param {*http.Request} [r] The net/http request instance
+@returns {*http.Request} The request to pass on to the router
+*/
+func TrackMatch(r *http.Request) *http.Request {
+	r, _ = withRouteContext(r)
+	return r
+}
+
+/**
+@info Reports whether a route matched the request, handlers and middleware inside the router
+can call it directly while middleware wrapping the router needs TrackMatch first
+@param {*http.Request} [r] The net/http request instance
+@returns {bool}
+*/
+func Matched(r *http.Request) bool {
+	rc := getRouteContext(r)
+	return rc != nil && rc.matched
+}
+
+/**
+@info Gets the path params of the matched route
+@param {*http.Request} [r] The net/http request instance
+@returns {map[string]string} nil when no route matched
+*/
+func Params(r *http.Request) map[string]string {
+	if rc := getRouteContext(r); rc != nil {
+		return rc.params
+	}
+	return nil
+}
+
+/**
+@info Gets the path template of the matched route, like /users/:id, for labelling metrics and traces
+@param {*http.Request} [r] The net/http request instance
+@returns {string} empty when no route matched
+*/
+func RouteTemplate(r *http.Request) string {
+	if rc := getRouteContext(r); rc != nil && rc.route != nil {
+		return rc.route.template()
+	}
+	return ""
+}
+
+/**
+@info Gets the extension of the request path when the matched route routes by it with Format, like json for
+/data.json
+@param {*http.Request} [r] The net/http request instance
+@returns {string} empty when the route doesn't use Format
+*/
+func Format(r *http.Request) string {
+	if rc := getRouteContext(r); rc != nil {
+		return rc.format
+	}
+	return ""
+}
+
+/**
+@info Gets the first value of a query param the matched route declared with Queries or QueryDefault
+@param {*http.Request} [r] The net/http request instance
+@param {string} [name] The query param name
+@returns {string} empty when the param wasn't declared
+*/
+func Query(r *http.Request, name string) string {
+	if values := QueryValues(r, name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+/**
+@info Gets the first value of any query param of the request, or a default when it's absent or empty
+@param {*http.Request} [r] The net/http request instance
+@param {string} [key] The query param name
+@param {string} [def] The default value
+@returns {string}
+*/
+func QueryDefault(r *http.Request, key string, def string) string {
+	if value := r.URL.Query().Get(key); value != "" {
+		return value
+	}
+	return def
+}
+
+/**
+@info Gets every value of a query param the matched route declared with Queries or QueryDefault
+@param {*http.Request} [r] The net/http request instance
+@param {string} [name] The query param name
+@returns {[]string} nil when the param wasn't declared
+*/
+func QueryValues(r *http.Request, name string) []string {
+	if rc := getRouteContext(r); rc != nil {
+		return rc.query[name]
+	}
+	return nil
+}
+
+/**
+@info Gets how long the router took to look the route up, apart from running the handler
+@param {*http.Request} [r] The net/http request instance
+@returns {time.Duration} 0 when the request never went through a router
+*/
+func MatchDuration(r *http.Request) time.Duration {
+	if rc := getRouteContext(r); rc != nil {
+		return rc.matchDuration
+	}
+	return 0
+}
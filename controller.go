@@ -0,0 +1,86 @@
+package mux
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var handlerType = reflect.TypeOf(Handler(nil))
+
+/**
+@info Registers the handler fields of a controller struct tagged with `route:"METHOD /path"`.
+Go doesn't allow tags on methods, so the controller exposes its handlers as func fields,
+typically assigned from its methods in the constructor
+@param {interface{}} [controller] The controller struct or a pointer to it
+@returns {error}
+*/
+func (r *Router) RegisterController(controller interface{}) error {
+	v := reflect.ValueOf(controller)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("controller must be a struct, got %T", controller)
+	}
+
+	type endpoint struct {
+		method  string
+		path    string
+		handler Handler
+	}
+	var endpoints []endpoint
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("route")
+		if !ok {
+			continue
+		}
+
+		parts := strings.Fields(tag)
+		if len(parts) != 2 || !strings.HasPrefix(parts[1], "/") {
+			return fmt.Errorf("field %s.%s has malformed route tag %q, want \"METHOD /path\"", t.Name(), field.Name, tag)
+		}
+		if field.PkgPath != "" {
+			return fmt.Errorf("field %s.%s must be exported to be registered", t.Name(), field.Name)
+		}
+		if !field.Type.ConvertibleTo(handlerType) {
+			return fmt.Errorf("field %s.%s has type %s, want func(http.ResponseWriter, *http.Request)", t.Name(), field.Name, field.Type)
+		}
+		fv := v.Field(i)
+		if fv.IsNil() {
+			return fmt.Errorf("field %s.%s has no handler assigned", t.Name(), field.Name)
+		}
+
+		method := strings.ToUpper(parts[0])
+		if !containsFold(methods, method) {
+			return fmt.Errorf("field %s.%s: %w %s", t.Name(), field.Name, ErrInvalidMethod, parts[0])
+		}
+		endpoints = append(endpoints, endpoint{
+			method:  method,
+			path:    parts[1],
+			handler: fv.Convert(handlerType).Interface().(Handler),
+		})
+	}
+
+	// The fields are checked up front and the endpoints registered under a
+	// single lock, a path failing to register takes the ones before it back
+	// out so the controller is never served half registered
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	registered := make([]*Route, 0, len(endpoints))
+	for _, e := range endpoints {
+		route, err := r.registerLocked(e.method, e.path, http.HandlerFunc(e.handler))
+		if err != nil {
+			for i, done := range registered {
+				r.routes[endpoints[i].method].remove(done)
+			}
+			return err
+		}
+		registered = append(registered, route)
+	}
+	return nil
+}
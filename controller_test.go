@@ -0,0 +1,99 @@
+package mux
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type userController struct {
+	Show   Handler          `route:"GET /users/:id"`
+	Create http.HandlerFunc `route:"post /users"`
+	Helper string
+}
+
+func newUserController(rt *Router) *userController {
+	c := &userController{Helper: "not a route"}
+	c.Show = func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("show:" + rt.GetParam(r, "id")))
+	}
+	c.Create = c.create
+	return c
+}
+
+func (c *userController) create(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("create"))
+}
+
+func TestRegisterController(t *testing.T) {
+	rt := NewRouter()
+	if err := rt.RegisterController(newUserController(rt)); err != nil {
+		t.Fatal(err)
+	}
+	if got := serve(rt, "GET", "/users/7").Body.String(); got != "show:7" {
+		t.Errorf("GET /users/7 = %q, want %q", got, "show:7")
+	}
+	if got := serve(rt, "POST", "/users").Body.String(); got != "create" {
+		t.Errorf("POST /users = %q, want %q", got, "create")
+	}
+}
+
+func TestRegisterControllerErrors(t *testing.T) {
+	tests := []struct {
+		controller interface{}
+		err        string
+	}{
+		{"not a struct", "must be a struct"},
+		{&struct {
+			Show Handler `route:"/users"`
+		}{Show: write("")}, "malformed route tag"},
+		{&struct {
+			Show Handler `route:"FETCH /users"`
+		}{Show: write("")}, "invalid method FETCH"},
+		{&struct {
+			Show func(http.ResponseWriter) `route:"GET /users"`
+		}{Show: func(http.ResponseWriter) {}}, "want func(http.ResponseWriter, *http.Request)"},
+		{&struct {
+			Show Handler `route:"GET /users"`
+		}{}, "no handler assigned"},
+	}
+	for _, tt := range tests {
+		rt := NewRouter()
+		err := rt.RegisterController(tt.controller)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("RegisterController(%T) error = %v, want it to mention %q", tt.controller, err, tt.err)
+		}
+		if routes := rt.Routes(); len(routes) != 0 {
+			t.Errorf("failed registration left routes behind: %v", routes)
+		}
+	}
+}
+
+func TestRegisterControllerConflict(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/health", write("ok"))
+
+	controllers := []interface{}{
+		&struct {
+			List Handler `route:"GET /users"`
+			Show Handler `route:"GET /users/:id"`
+			Find Handler `route:"GET /users/:name"`
+		}{List: write(""), Show: write(""), Find: write("")},
+		&struct {
+			List   Handler `route:"GET /users"`
+			Health Handler `route:"GET /health"`
+		}{List: write(""), Health: write("")},
+	}
+	for i, c := range controllers {
+		if err := rt.RegisterController(c); !errors.Is(err, ErrRouteConflict) {
+			t.Errorf("controller %d: error = %v, want a route conflict", i, err)
+		}
+		if routes := rt.Routes(); len(routes) != 1 || routes[0].Template != "/health" {
+			t.Errorf("controller %d: routes = %+v, want only the existing /health", i, routes)
+		}
+		if w := serve(rt, "GET", "/users"); w.Code != http.StatusNotFound {
+			t.Errorf("controller %d: GET /users = %d, want it rolled back", i, w.Code)
+		}
+	}
+}
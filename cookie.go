@@ -0,0 +1,196 @@
+package mux
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The errors returned by CookieStore.Get, test for them with errors.Is
+var (
+	// The cookie value was tampered with or wasn't written by this store
+	ErrInvalidCookie = errors.New("invalid cookie")
+	// The cookie value is past its expiry
+	ErrCookieExpired = errors.New("cookie expired")
+)
+
+/**
+ * @info Signs, and optionally encrypts, cookie values so tampering is detected
+ * @property {string} [Path] The cookie path, defaults to /
+ * @property {string} [Domain] The cookie domain
+ * @property {time.Duration} [MaxAge] How long the cookies stay valid, 0 makes session cookies
+ * @property {bool} [Secure] Whether the cookies are only sent over HTTPS
+ * @property {bool} [HttpOnly] Whether the cookies are hidden from scripts, defaults to true
+ * @property {http.SameSite} [SameSite] The SameSite mode, defaults to Lax
+ * @property {bool} [Encrypt] Whether the values are encrypted as well as signed
+ * @property {[]byte} [hashKey] The HMAC key
+ * @property {cipher.AEAD} [aead] The cipher encrypting the values
+ */
+type CookieStore struct {
+	Path     string
+	Domain   string
+	MaxAge   time.Duration
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+	Encrypt  bool
+	hashKey  []byte
+	aead     cipher.AEAD
+}
+
+/**
+@info Makes a new cookie store, the signing and encryption keys are both derived from key
+@param {[]byte} [key] The secret key, at least 32 random bytes
+@returns {*CookieStore}
+*/
+func NewCookieStore(key []byte) *CookieStore {
+	hashKey := sha256.Sum256(append([]byte("minima-cookie-hash:"), key...))
+	blockKey := sha256.Sum256(append([]byte("minima-cookie-block:"), key...))
+	block, err := aes.NewCipher(blockKey[:])
+	if err != nil {
+		panic("Minima: " + err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic("Minima: " + err.Error())
+	}
+	return &CookieStore{
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		hashKey:  hashKey[:],
+		aead:     aead,
+	}
+}
+
+/**
+@info Encodes a value as JSON and sets it as a signed cookie
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {string} [name] The cookie name
+@param {interface{}} [value] The value to store
+@returns {error}
+*/
+func (s *CookieStore) Set(w http.ResponseWriter, name string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if s.Encrypt {
+		nonce := make([]byte, s.aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return err
+		}
+		data = s.aead.Seal(nonce, nonce, data, []byte(name))
+	}
+
+	var expires int64
+	cookie := s.cookie(name)
+	if s.MaxAge > 0 {
+		cookie.Expires = time.Now().Add(s.MaxAge)
+		cookie.MaxAge = int(s.MaxAge / time.Second)
+		expires = cookie.Expires.Unix()
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data) + "." + strconv.FormatInt(expires, 10)
+	cookie.Value = payload + "." + base64.RawURLEncoding.EncodeToString(s.sign(name, payload))
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+/**
+@info Verifies a signed cookie and decodes its JSON value into dst
+@param {*http.Request} [r] The net/http request instance
+@param {string} [name] The cookie name
+@param {interface{}} [dst] A pointer to decode the value into
+@returns {error} http.ErrNoCookie, ErrInvalidCookie or ErrCookieExpired
+*/
+func (s *CookieStore) Get(r *http.Request, name string, dst interface{}) error {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return err
+	}
+	i := strings.LastIndexByte(c.Value, '.')
+	if i < 0 {
+		return ErrInvalidCookie
+	}
+	payload := c.Value[:i]
+	mac, err := base64.RawURLEncoding.DecodeString(c.Value[i+1:])
+	if err != nil || !hmac.Equal(mac, s.sign(name, payload)) {
+		return ErrInvalidCookie
+	}
+
+	j := strings.IndexByte(payload, '.')
+	if j < 0 {
+		return ErrInvalidCookie
+	}
+	expires, err := strconv.ParseInt(payload[j+1:], 10, 64)
+	if err != nil {
+		return ErrInvalidCookie
+	}
+	if expires != 0 && time.Now().Unix() > expires {
+		return ErrCookieExpired
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload[:j])
+	if err != nil {
+		return ErrInvalidCookie
+	}
+	if s.Encrypt {
+		size := s.aead.NonceSize()
+		if len(data) < size {
+			return ErrInvalidCookie
+		}
+		if data, err = s.aead.Open(nil, data[:size], data[size:], []byte(name)); err != nil {
+			return ErrInvalidCookie
+		}
+	}
+	return json.Unmarshal(data, dst)
+}
+
+/**
+@info Removes a cookie from the client
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {string} [name] The cookie name
+*/
+func (s *CookieStore) Delete(w http.ResponseWriter, name string) {
+	cookie := s.cookie(name)
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(1, 0)
+	http.SetCookie(w, cookie)
+}
+
+/**
+@info Makes a cookie with the store attributes
+@param {string} [name] The cookie name
+@returns {*http.Cookie}
+*/
+func (s *CookieStore) cookie(name string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Path:     s.Path,
+		Domain:   s.Domain,
+		Secure:   s.Secure,
+		HttpOnly: s.HttpOnly,
+		SameSite: s.SameSite,
+	}
+}
+
+/**
+@info Computes the HMAC of a cookie payload, bound to the cookie name so values can't be swapped
+@param {string} [name] The cookie name
+@param {string} [payload] The encoded value and expiry
+@returns {[]byte}
+*/
+func (s *CookieStore) sign(name, payload string) []byte {
+	h := hmac.New(sha256.New, s.hashKey)
+	io.WriteString(h, name+"|"+payload)
+	return h.Sum(nil)
+}
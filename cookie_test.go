@@ -0,0 +1,97 @@
+package mux
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type session struct {
+	User string `json:"user"`
+	ID   int    `json:"id"`
+}
+
+func roundTrip(t *testing.T, s *CookieStore, value interface{}) *http.Request {
+	t.Helper()
+	w := httptest.NewRecorder()
+	if err := s.Set(w, "session", value); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+func TestCookieStore(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		s := NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+		s.Encrypt = encrypt
+		req := roundTrip(t, s, session{User: "ada", ID: 7})
+
+		var got session
+		if err := s.Get(req, "session", &got); err != nil {
+			t.Fatalf("encrypt=%v: unexpected error: %v", encrypt, err)
+		}
+		if got.User != "ada" || got.ID != 7 {
+			t.Errorf("encrypt=%v: unexpected value %+v", encrypt, got)
+		}
+		c, _ := req.Cookie("session")
+		if encrypt == strings.Contains(c.Value, "eyJ1c2VyIjoiYWRhIi") {
+			t.Errorf("encrypt=%v: unexpected cookie value %q", encrypt, c.Value)
+		}
+	}
+}
+
+func TestCookieStoreTampering(t *testing.T) {
+	s := NewCookieStore([]byte("secret"))
+	req := roundTrip(t, s, session{User: "ada"})
+	c, _ := req.Cookie("session")
+
+	tampered := httptest.NewRequest(http.MethodGet, "/", nil)
+	tampered.AddCookie(&http.Cookie{Name: "session", Value: "x" + c.Value})
+	var got session
+	if err := s.Get(tampered, "session", &got); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("expected ErrInvalidCookie, got %v", err)
+	}
+
+	renamed := httptest.NewRequest(http.MethodGet, "/", nil)
+	renamed.AddCookie(&http.Cookie{Name: "other", Value: c.Value})
+	if err := s.Get(renamed, "other", &got); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("expected ErrInvalidCookie for a renamed cookie, got %v", err)
+	}
+
+	if err := NewCookieStore([]byte("other")).Get(req, "session", &got); !errors.Is(err, ErrInvalidCookie) {
+		t.Errorf("expected ErrInvalidCookie for another key, got %v", err)
+	}
+	if err := s.Get(httptest.NewRequest(http.MethodGet, "/", nil), "session", &got); err != http.ErrNoCookie {
+		t.Errorf("expected http.ErrNoCookie, got %v", err)
+	}
+}
+
+func TestCookieStoreOptions(t *testing.T) {
+	s := NewCookieStore([]byte("secret"))
+	s.MaxAge = time.Hour
+	s.Secure = true
+	s.SameSite = http.SameSiteStrictMode
+
+	w := httptest.NewRecorder()
+	s.Set(w, "session", "v")
+	c := w.Result().Cookies()[0]
+	if !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteStrictMode || c.MaxAge != 3600 || c.Path != "/" {
+		t.Errorf("unexpected cookie attributes %+v", c)
+	}
+
+	payload := "InYi.1"
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: payload + "." + base64.RawURLEncoding.EncodeToString(s.sign("session", payload))})
+	var got string
+	if err := s.Get(req, "session", &got); !errors.Is(err, ErrCookieExpired) {
+		t.Errorf("expected ErrCookieExpired, got %v", err)
+	}
+}
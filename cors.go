@@ -0,0 +1,164 @@
+package mux
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * @info The CORS policy of a route
+ * @property {[]string} [AllowedOrigins] The origins allowed to call the route, * allows any
+ * @property {[]string} [AllowedMethods] The methods preflight requests may ask for, empty allows the route method
+ * @property {[]string} [AllowedHeaders] The request headers preflight requests may ask for, * allows any
+ * @property {[]string} [ExposedHeaders] The response headers the browser exposes to the caller
+ * @property {bool} [AllowCredentials] Lets the browser send cookies, the origins have to be listed since * can't carry them
+ * @property {time.Duration} [MaxAge] How long browsers may cache the preflight response, 0 leaves it to them
+ */
+type CORSOptions struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+/**
+@info Sets the CORS policy of the route, applied to its responses and to the preflight requests for it.
+It panics when credentials are allowed for any origin, which would let every site read the route as the user
+@param {CORSOptions} [opts] The policy
+@returns {*Route}
+*/
+func (r *Route) CORS(opts CORSOptions) *Route {
+	if opts.AllowCredentials && opts.anyOrigin() {
+		panic("Minima: CORS can't allow credentials for any origin, list the allowed origins instead of *")
+	}
+	r.cors = &opts
+	return r
+}
+
+/**
+@info Reports whether the policy allows any origin with *, the responses are then the same for every origin
+@returns {bool}
+*/
+func (c *CORSOptions) anyOrigin() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+@info Reports whether an origin is allowed
+@param {string} [origin] The Origin header
+@returns {bool}
+*/
+func (c *CORSOptions) allowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+@info Sets the origin headers shared by the actual and preflight responses
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {string} [origin] The allowed Origin header
+*/
+func (c *CORSOptions) allowOrigin(w http.ResponseWriter, origin string) {
+	if c.anyOrigin() {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+/**
+@info Sets the CORS headers of an actual request served by the route
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {*http.Request} [req] The net/http request instance
+*/
+func (c *CORSOptions) apply(w http.ResponseWriter, req *http.Request) {
+	// The headers depend on the origin unless any is allowed, caches have to
+	// tell the responses apart even when this origin is refused
+	if !c.anyOrigin() {
+		AddVary(w, "Origin")
+	}
+	origin := req.Header.Get("Origin")
+	if origin == "" || !c.allowsOrigin(origin) {
+		return
+	}
+	c.allowOrigin(w, origin)
+	if len(c.ExposedHeaders) > 0 {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+	}
+}
+
+/**
+@info Sets the CORS headers of a preflight request, leaving them out when the policy refuses it
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {*http.Request} [req] The net/http request instance
+@param {string} [method] The method of the route the preflight is for
+*/
+func (c *CORSOptions) preflight(w http.ResponseWriter, req *http.Request, method string) {
+	if !c.anyOrigin() {
+		AddVary(w, "Origin")
+	}
+	AddVary(w, "Access-Control-Request-Method")
+	AddVary(w, "Access-Control-Request-Headers")
+	origin := req.Header.Get("Origin")
+	if origin == "" || !c.allowsOrigin(origin) {
+		return
+	}
+	requested := strings.ToUpper(req.Header.Get("Access-Control-Request-Method"))
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{method}
+	}
+	if !containsFold(methods, requested) {
+		return
+	}
+	var headers []string
+	for _, h := range strings.Split(req.Header.Get("Access-Control-Request-Headers"), ",") {
+		if h = strings.TrimSpace(h); h == "" {
+			continue
+		}
+		if !containsFold(c.AllowedHeaders, h) && !containsFold(c.AllowedHeaders, "*") {
+			return
+		}
+		headers = append(headers, h)
+	}
+
+	c.allowOrigin(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(headers) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if c.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+}
+
+/**
+@info Reports whether a list holds a value, ignoring case
+@param {[]string} [list] The list
+@param {string} [value] The value
+@returns {bool}
+*/
+func containsFold(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouteCORS(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/public", write("public")).CORS(CORSOptions{AllowedOrigins: []string{"*"}})
+	rt.Post("/account", write("account")).CORS(CORSOptions{
+		AllowedOrigins:   []string{"https://app.example"},
+		AllowedHeaders:   []string{"Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	rt.Get("/plain", write("plain"))
+
+	tests := []struct {
+		method, path, origin, requestMethod, requestHeaders string
+		allowOrigin, allowMethods, allowHeaders             string
+	}{
+		{http.MethodGet, "/public", "https://any.example", "", "", "*", "", ""},
+		{http.MethodPost, "/account", "https://app.example", "", "", "https://app.example", "", ""},
+		{http.MethodPost, "/account", "https://evil.example", "", "", "", "", ""},
+		{http.MethodGet, "/plain", "https://app.example", "", "", "", "", ""},
+		{http.MethodOptions, "/account", "https://app.example", "POST", "content-type", "https://app.example", "POST", "content-type"},
+		{http.MethodOptions, "/account", "https://evil.example", "POST", "", "", "", ""},
+		{http.MethodOptions, "/account", "https://app.example", "POST", "X-Secret", "", "", ""},
+		{http.MethodOptions, "/public", "https://any.example", "GET", "", "*", "GET", ""},
+		{http.MethodOptions, "/public", "https://any.example", "DELETE", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Origin", tt.origin)
+		if tt.requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+		}
+		if tt.requestHeaders != "" {
+			req.Header.Set("Access-Control-Request-Headers", tt.requestHeaders)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		h := w.Header()
+		if h.Get("Access-Control-Allow-Origin") != tt.allowOrigin || h.Get("Access-Control-Allow-Methods") != tt.allowMethods || h.Get("Access-Control-Allow-Headers") != tt.allowHeaders {
+			t.Errorf("%s %s from %s: got origin %q methods %q headers %q, want %q %q %q", tt.method, tt.path, tt.origin,
+				h.Get("Access-Control-Allow-Origin"), h.Get("Access-Control-Allow-Methods"), h.Get("Access-Control-Allow-Headers"),
+				tt.allowOrigin, tt.allowMethods, tt.allowHeaders)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/account", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("preflight = %d %v, want 204 with credentials and a max age of 600", w.Code, w.Header())
+	}
+
+	// Refused and origin-less responses vary on Origin too, so caches don't
+	// hand them to an allowed origin
+	for _, origin := range []string{"https://evil.example", ""} {
+		req := httptest.NewRequest(http.MethodPost, "/account", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if got := w.Header().Get("Vary"); got != "Origin" {
+			t.Errorf("POST /account from %q: Vary = %q, want Origin", origin, got)
+		}
+	}
+	if got := serve(rt, http.MethodGet, "/public").Header().Get("Vary"); got != "" {
+		t.Errorf("GET /public: Vary = %q, want none for a policy allowing any origin", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for credentials allowed to any origin")
+		}
+	}()
+	rt.Get("/open", write("open")).CORS(CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+}
@@ -0,0 +1,50 @@
+// Package debug mounts the net/http/pprof and expvar handlers on a mux router. It lives apart from
+// the mux package because importing those packages registers their handlers on http.DefaultServeMux,
+// so only the programs opting into them pay for it
+package debug
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gominima/mux"
+)
+
+/**
+@info Mounts the pprof index and profiles under a prefix, like /debug/pprof
+@param {*mux.Router} [rt] The router to register on
+@param {string} [prefix] The path prefix
+@returns {*mux.Router}
+*/
+func Pprof(rt *mux.Router, prefix string) *mux.Router {
+	return rt.Handle(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The router strips the prefix, so dispatch on what's left instead
+		// of letting pprof.Index look for its usual /debug/pprof/ one
+		switch name := strings.TrimPrefix(r.URL.Path, "/"); name {
+		case "":
+			pprof.Index(w, r)
+		case "cmdline":
+			pprof.Cmdline(w, r)
+		case "profile":
+			pprof.Profile(w, r)
+		case "symbol":
+			pprof.Symbol(w, r)
+		case "trace":
+			pprof.Trace(w, r)
+		default:
+			pprof.Handler(name).ServeHTTP(w, r)
+		}
+	}))
+}
+
+/**
+@info Mounts the expvar variables as JSON on a path, like /debug/vars
+@param {*mux.Router} [rt] The router to register on
+@param {string} [path] The route path
+@returns {*mux.RouteBuilder}
+*/
+func Expvar(rt *mux.Router, path string) *mux.RouteBuilder {
+	return rt.Get(path, expvar.Handler().ServeHTTP)
+}
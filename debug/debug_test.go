@@ -0,0 +1,39 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gominima/mux"
+)
+
+func TestDebugRoutes(t *testing.T) {
+	rt := mux.NewRouter()
+	Pprof(rt, "/debug/pprof")
+	Expvar(rt, "/debug/vars")
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/debug/pprof/", "goroutine"},
+		{"/debug/pprof/goroutine?debug=1", "goroutine profile"},
+		{"/debug/pprof/cmdline", ""},
+		{"/debug/vars", `"memstats"`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("GET %s = %d, want 200 containing %q", tt.path, w.Code, tt.body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	mux.NewRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /debug/pprof/ without Pprof = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,17 @@
+package mux
+
+import "errors"
+
+// The errors returned by route registration, test for them with errors.Is
+var (
+	// The route method isn't one the router serves
+	ErrInvalidMethod = errors.New("invalid method")
+	// The route path can't be parsed
+	ErrInvalidPath = errors.New("invalid path")
+	// The route path uses the same param name twice
+	ErrDuplicateParam = errors.New("duplicate param")
+	// An unconstrained route with the same shape is already registered, the new one would never match
+	ErrRouteConflict = errors.New("route conflict")
+	// The router was frozen with Freeze, its routes and middleware can't change anymore
+	ErrRouterFrozen = errors.New("router frozen")
+)
@@ -1,3 +1,5 @@
 module github.com/gominima/mux
 
-go 1.17
+go 1.21
+
+require golang.org/x/text v0.14.0
@@ -0,0 +1,198 @@
+package mux
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/**
+ * @info A response cached by the Idempotency middleware
+ * @property {int} [Status] The response status code
+ * @property {http.Header} [Header] The response headers
+ * @property {[]byte} [Body] The response body
+ */
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// The storage the Idempotency middleware caches responses in, implementations decide how long they're kept
+type Store interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, res *CachedResponse)
+}
+
+/**
+ * @info An in-memory Store expiring responses after a TTL
+ * @property {time.Duration} [ttl] How long the responses are kept
+ * @property {map[string]memoryEntry} [entries] The cached responses
+ * @property {sync.Mutex} [mu] Guards the entries
+ */
+type MemoryStore struct {
+	ttl     time.Duration
+	entries map[string]memoryEntry
+	mu      sync.Mutex
+}
+
+// A cached response and when it expires
+type memoryEntry struct {
+	res     *CachedResponse
+	expires time.Time
+}
+
+/**
+@info Makes a new in-memory store
+@param {time.Duration} [ttl] How long the responses are kept
+@returns {*MemoryStore}
+*/
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, entries: make(map[string]memoryEntry)}
+}
+
+/**
+@info Gets a cached response that hasn't expired
+@param {string} [key] The cache key
+@returns {*CachedResponse, bool}
+*/
+func (s *MemoryStore) Get(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return e.res, true
+}
+
+/**
+@info Caches a response for the store TTL, dropping the expired ones
+@param {string} [key] The cache key
+@param {*CachedResponse} [res] The response to cache
+*/
+func (s *MemoryStore) Set(key string, res *CachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = memoryEntry{res: res, expires: now.Add(s.ttl)}
+}
+
+/**
+@info Creates a middleware replaying the first response for requests repeating an Idempotency-Key header.
+Keys are scoped to the method and path, 5xx responses aren't cached so the client can retry, and a
+duplicate arriving while the first request is still in flight gets 409
+@param {Store} [store] The storage for the cached responses
+@returns {func(http.Handler) http.Handler}
+*/
+func Idempotency(store Store) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+	inFlight := make(map[string]bool)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Idempotency-Key")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := r.Method + " " + r.URL.Path + " " + header
+			if res, ok := store.Get(key); ok {
+				replay(w, res)
+				return
+			}
+
+			mu.Lock()
+			if inFlight[key] {
+				mu.Unlock()
+				http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+				return
+			}
+			inFlight[key] = true
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				delete(inFlight, key)
+				mu.Unlock()
+			}()
+
+			// The response may have been cached between the lookup and taking the key
+			if res, ok := store.Get(key); ok {
+				replay(w, res)
+				return
+			}
+			cw := &captureWriter{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+			if cw.status == 0 {
+				cw.status, cw.header = http.StatusOK, w.Header().Clone()
+			}
+			if cw.status < http.StatusInternalServerError {
+				store.Set(key, &CachedResponse{Status: cw.status, Header: cw.header, Body: cw.body.Bytes()})
+			}
+		})
+	}
+}
+
+/**
+@info Writes a cached response, marked with the Idempotent-Replayed header
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {*CachedResponse} [res] The cached response
+*/
+func replay(w http.ResponseWriter, res *CachedResponse) {
+	for name, values := range res.Header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(res.Status)
+	w.Write(res.Body)
+}
+
+// The response writer wrapper recording the status, headers and body it sends along
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+/**
+@info Sends the response header, recording it
+@param {int} [status] The response status code
+*/
+func (w *captureWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+/**
+@info Writes the response body, recording it
+@param {[]byte} [b] The body bytes
+@returns {int, error}
+*/
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+/**
+@info Gets the wrapped response instance
+@returns {http.ResponseWriter}
+*/
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,85 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	charges := 0
+	rt := NewRouter()
+	rt.Post("/payments", func(w http.ResponseWriter, r *http.Request) {
+		charges++
+		w.Header().Set("X-Charge", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("charged"))
+	}).Middleware(Idempotency(NewMemoryStore(time.Minute)))
+
+	post := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("abc")
+	replayed := post("abc")
+	if charges != 1 {
+		t.Errorf("expected a single charge, got %d", charges)
+	}
+	if replayed.Code != http.StatusCreated || replayed.Body.String() != "charged" || replayed.Header().Get("X-Charge") != "1" {
+		t.Errorf("unexpected replay %d %q %v", replayed.Code, replayed.Body.String(), replayed.Header())
+	}
+	if replayed.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("expected only the replay to be marked")
+	}
+
+	post("other")
+	post("")
+	post("")
+	if charges != 4 {
+		t.Errorf("expected new keys and keyless requests to run, got %d charges", charges)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := Idempotency(NewMemoryStore(time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	req := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		r.Header.Set("Idempotency-Key", "abc")
+		return r
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(httptest.NewRecorder(), req())
+	}()
+	<-started
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req())
+	close(release)
+	wg.Wait()
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for an in-flight duplicate, got %d", w.Code)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	s := NewMemoryStore(-time.Second)
+	s.Set("k", &CachedResponse{Status: http.StatusOK})
+	if _, ok := s.Get("k"); ok {
+		t.Error("expected the response to have expired")
+	}
+}
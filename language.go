@@ -0,0 +1,90 @@
+package mux
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/**
+@info Parses a header listing values with quality weights, like Accept-Encoding or Accept-Language
+@param {string} [header] The header value
+@returns {map[string]float64} The lowercased values and their quality, 1 when not given
+*/
+func parseQualities(header string) map[string]float64 {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, q := strings.TrimSpace(part), 1.0
+		if i := strings.IndexByte(name, ';'); i >= 0 {
+			param := strings.TrimSpace(name[i+1:])
+			name = strings.TrimSpace(name[:i])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if name != "" {
+			qualities[strings.ToLower(name)] = q
+		}
+	}
+	return qualities
+}
+
+/**
+@info Picks the supported language the client prefers according to its Accept-Language header.
+A range matches its own tag and the more specific ones, en matching en-US, and a region tag falls back
+to its language, en-US matching en, when nothing closer is listed. Ties go to the earlier supported language
+@param {*http.Request} [r] The net/http request instance
+@param {...string} [supported] The languages the handler can serve, the first one is the default
+@returns {string} The default when nothing matches, empty without supported languages
+*/
+func PreferredLanguage(r *http.Request, supported ...string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+	qualities := parseQualities(r.Header.Get("Accept-Language"))
+	best, bestQ, bestRank := supported[0], 0.0, 0
+	for _, lang := range supported {
+		q, rank := languageQuality(qualities, strings.ToLower(lang))
+		if q > bestQ || q == bestQ && q > 0 && rank > bestRank {
+			best, bestQ, bestRank = lang, q, rank
+		}
+	}
+	return best
+}
+
+/**
+@info Finds the quality the client gives a language through its most specific matching range
+@param {map[string]float64} [qualities] The parsed Accept-Language ranges
+@param {string} [lang] The lowercased language tag
+@returns {float64, int} The quality and how specific the match was, higher being closer
+*/
+func languageQuality(qualities map[string]float64, lang string) (float64, int) {
+	// The tag itself, then shorter ranges covering it like en for en-us
+	for rank, tag := 3+strings.Count(lang, "-"), lang; ; rank-- {
+		if q, ok := qualities[tag]; ok {
+			return q, rank
+		}
+		i := strings.LastIndexByte(tag, '-')
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	// Longer ranges the language covers, like en-us for en
+	var q float64
+	found := false
+	for tag, tq := range qualities {
+		if strings.HasPrefix(tag, lang+"-") && (!found || tq > q) {
+			q, found = tq, true
+		}
+	}
+	if found {
+		return q, 2
+	}
+	if q, ok := qualities["*"]; ok {
+		return q, 1
+	}
+	return 0, 0
+}
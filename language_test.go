@@ -0,0 +1,37 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header    string
+		supported []string
+		want      string
+	}{
+		{"", []string{"en", "fr"}, "en"},
+		{"fr", []string{"en", "fr"}, "fr"},
+		{"de, fr;q=0.8, en;q=0.5", []string{"en", "fr"}, "fr"},
+		{"en-US", []string{"fr", "en"}, "en"},
+		{"en", []string{"fr", "en-GB"}, "en-GB"},
+		{"en-GB, en;q=0.9", []string{"en-US", "en-GB"}, "en-GB"},
+		{"pt-BR", []string{"pt-PT", "pt-BR"}, "pt-BR"},
+		{"de, *;q=0.1", []string{"en", "fr"}, "en"},
+		{"*, fr;q=0", []string{"fr", "en"}, "en"},
+		{"ja", []string{"en", "fr"}, "en"},
+		{"FR-ca;q=0.7, es;q=0.6", []string{"es", "fr"}, "fr"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Language", tt.header)
+		if got := PreferredLanguage(r, tt.supported...); got != tt.want {
+			t.Errorf("PreferredLanguage(%q, %v) = %q, want %q", tt.header, tt.supported, got, tt.want)
+		}
+	}
+	if got := PreferredLanguage(httptest.NewRequest(http.MethodGet, "/", nil)); got != "" {
+		t.Errorf("expected no language without supported ones, got %q", got)
+	}
+}
@@ -0,0 +1,90 @@
+package mux
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+/**
+ * @info The measurements of a served request
+ * @property {string} [Method] The request method
+ * @property {string} [Route] The template of the matched route, empty when none matched
+ * @property {int} [Status] The response status code
+ * @property {time.Duration} [Duration] How long serving the request took
+ * @property {time.Duration} [MatchDuration] How long the router took to look the route up, the lookup happens before the router middleware runs
+ * @property {int64} [RequestBytes] The request body size, -1 when unknown
+ * @property {int64} [ResponseBytes] The response body bytes written
+ */
+type RequestMetrics struct {
+	Method        string
+	Route         string
+	Status        int
+	Duration      time.Duration
+	MatchDuration time.Duration
+	RequestBytes  int64
+	ResponseBytes int64
+}
+
+// Receives the measurements of every request the Metrics middleware serves
+type MetricsObserver interface {
+	Observe(m RequestMetrics)
+}
+
+/**
+@info Creates a middleware measuring the request and response sizes, status and duration of every
+request and reporting them to an observer. The request size is the Content-Length, or the bytes the
+handler read when the length isn't declared
+@param {MetricsObserver} [observer] The observer to report to
+@returns {func(http.Handler) http.Handler}
+*/
+func Metrics(observer MetricsObserver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			var body *countingReader
+			if r.ContentLength < 0 && r.Body != nil {
+				body = &countingReader{ReadCloser: r.Body}
+				r.Body = body
+			}
+			rw := newResponseWriter(w, http.StatusOK)
+			next.ServeHTTP(rw, r)
+
+			m := RequestMetrics{
+				Method:        r.Method,
+				Status:        rw.status,
+				Duration:      time.Since(start),
+				RequestBytes:  r.ContentLength,
+				ResponseBytes: rw.written,
+			}
+			if m.Status == 0 {
+				m.Status = http.StatusOK
+			}
+			if body != nil {
+				m.RequestBytes = body.n
+			}
+			if rc := getRouteContext(r); rc != nil && rc.route != nil {
+				m.Route = rc.route.template()
+			}
+			m.MatchDuration = MatchDuration(r)
+			observer.Observe(m)
+		})
+	}
+}
+
+// The request body wrapper counting the bytes read
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+/**
+@info Reads from the body, counting the bytes
+@param {[]byte} [p] The buffer to read into
+@returns {int, error}
+*/
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
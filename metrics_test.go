@@ -0,0 +1,72 @@
+package mux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type metricsRecorder []RequestMetrics
+
+func (m *metricsRecorder) Observe(rm RequestMetrics) {
+	*m = append(*m, rm)
+}
+
+func TestMetrics(t *testing.T) {
+	var observed metricsRecorder
+	rt := NewRouter()
+	rt.UseRaw(Metrics(&observed))
+	rt.Post("/upload/:name", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("stored"))
+	})
+	rt.Fallback(http.NotFoundHandler())
+
+	rt.TestRequest(http.MethodPost, "/upload/a", strings.NewReader("12345"))
+
+	chunked := httptest.NewRequest(http.MethodPost, "/upload/b", io.NopCloser(strings.NewReader("1234567")))
+	chunked.ContentLength = -1
+	rt.ServeHTTP(httptest.NewRecorder(), chunked)
+
+	rt.TestRequest(http.MethodGet, "/missing", nil)
+
+	want := []RequestMetrics{
+		{Method: "POST", Route: "/upload/:name", Status: http.StatusCreated, RequestBytes: 5, ResponseBytes: 6},
+		{Method: "POST", Route: "/upload/:name", Status: http.StatusCreated, RequestBytes: 7, ResponseBytes: 6},
+		{Method: "GET", Status: http.StatusNotFound, RequestBytes: 0, ResponseBytes: 19},
+	}
+	if len(observed) != len(want) {
+		t.Fatalf("expected %d observations, got %d", len(want), len(observed))
+	}
+	for i, m := range observed {
+		if m.Duration <= 0 {
+			t.Errorf("observation %d: expected a duration", i)
+		}
+		if m.MatchDuration <= 0 {
+			t.Errorf("observation %d: expected a match duration", i)
+		}
+		m.Duration, m.MatchDuration = 0, 0
+		if m != want[i] {
+			t.Errorf("observation %d: expected %+v, got %+v", i, want[i], m)
+		}
+	}
+}
+
+func TestMatchDuration(t *testing.T) {
+	rt := NewRouter()
+	var got time.Duration
+	rt.Get("/a/b/c/d/e/:f/:g/:h/:i/:j", func(w http.ResponseWriter, r *http.Request) {
+		got = MatchDuration(r)
+	})
+	serve(rt, http.MethodGet, "/a/b/c/d/e/f/g/h/i/j")
+	if got <= 0 {
+		t.Errorf("expected a match duration, got %s", got)
+	}
+	if d := MatchDuration(httptest.NewRequest(http.MethodGet, "/", nil)); d != 0 {
+		t.Errorf("expected no match duration outside a router, got %s", d)
+	}
+}
@@ -1,90 +1,803 @@
 package mux
 
 import (
+	"fmt"
+	"net"
 	"net/http"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
-
 type param struct {
-	name  string
-	fixed bool
+	name     string
+	fixed    bool
+	wildcard bool
+	kind     string
+	matcher  *regexp.Regexp
 }
 
-type Route struct {
-	prefix    string
-	partNames []param
-	function  http.Handler
+// The shorthand param types usable as :name|type in route paths
+var paramTypes = map[string]*regexp.Regexp{
+	"int":  regexp.MustCompile(`^-?[0-9]+$`),
+	"slug": regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`),
+	"uuid": regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`),
 }
 
+type Route struct {
+	// First so it's 64-bit aligned for the atomic operations on 32-bit platforms
+	hits          uint64
+	prefix        string
+	partNames     []param
+	function      http.Handler
+	validators    map[string]func(string) bool
+	deprecated    bool
+	sunset        time.Time
+	greedy        bool
+	name          string
+	matchers      []func(*http.Request) bool
+	cacheControl  string
+	source        string
+	middlewares   []func(http.Handler) http.Handler
+	status        int
+	stacks        []string
+	queries       []string
+	queryDefaults map[string]string
+	method        string
+	healthCheck   bool
+	cors          *CORSOptions
+	tags          []string
+	formats       []string
+	// The builders of the middleware holding state, by index in middlewares,
+	// so copies of the route get state of their own
+	stateful map[int]func() func(http.Handler) http.Handler
+}
 
 type Routes struct {
-	roots map[string][]Route
-	
+	roots map[string][]*Route
 }
 
-
 func NewRoutes() *Routes {
 	return &Routes{
-		roots: make(map[string][]Route),
+		roots: make(map[string][]*Route),
+	}
+}
+
+/**
+@info Adds a route to the routes table
+@param {string} [path] The route path
+@param {http.Handler} [f] The handler for the given route
+@returns {*Route, error}
+*/
+func (r *Routes) Add(path string, f http.Handler) (*Route, error) {
+	return r.add(path, f, "")
+}
 
+/**
+@info Reports whether a param name only holds letters, digits, _ and -. Other characters, dots
+especially, are reserved for the path syntax
+@param {string} [name] The param name
+@returns {bool}
+*/
+func validParamName(name string) bool {
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
 	}
+	return true
 }
 
+/**
+@info Adds a route to the routes table, remembering where it was registered
+@param {string} [path] The route path
+@param {http.Handler} [f] The handler for the given route
+@param {string} [source] The file:line the route was registered at, empty when unknown
+@returns {*Route, error}
+*/
+func (r *Routes) add(path string, f http.Handler, source string) (*Route, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("%w %q: must start with /", ErrInvalidPath, path)
+	}
 
-func (r *Routes) Add(path string, f http.Handler) {
 	parts := strings.Split(path, "/")
 	var rootParts []string
 	var varParts []param
 	var paramsFound bool
+	seen := make(map[string]bool)
 	for _, p := range parts {
-		if strings.HasPrefix(p, ":") {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
 			paramsFound = true
 		}
 
-		if paramsFound {
-			if strings.HasPrefix(p, ":") {
-				varParts = append(varParts, param{
-					name:  strings.TrimPrefix(p, ":"),
-					fixed: false,
-				})
-			} else {
-				varParts = append(varParts, param{
-					name:  p,
-					fixed: true,
-				})
-			}
-		} else {
+		if !paramsFound {
 			rootParts = append(rootParts, p)
+			continue
 		}
-	}
+		if p == "" {
+			continue
+		}
+		if len(varParts) > 0 && varParts[len(varParts)-1].wildcard {
+			return nil, fmt.Errorf("%w %q: wildcard must be the last segment", ErrInvalidPath, path)
+		}
+
+		if !strings.HasPrefix(p, ":") && !strings.HasPrefix(p, "*") {
+			varParts = append(varParts, param{
+				name:  p,
+				fixed: true,
+			})
+			continue
+		}
+
+		name := p[1:]
+		var kind string
+		var matcher *regexp.Regexp
+		if i := strings.Index(name, "|"); i >= 0 && p[0] == ':' {
+			name, kind = name[:i], name[i+1:]
+			var ok bool
+			if matcher, ok = paramTypes[kind]; !ok {
+				return nil, fmt.Errorf("%w %q: unknown param type %q", ErrInvalidPath, path, kind)
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("%w %q: unnamed param", ErrInvalidPath, path)
+		}
+		if !validParamName(name) {
+			return nil, fmt.Errorf("%w %q: param name %q can only hold letters, digits, _ and -", ErrInvalidPath, path, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w %s in route %q", ErrDuplicateParam, name, path)
+		}
+		seen[name] = true
 
-	root := strings.Join(rootParts, "/")
+		varParts = append(varParts, param{
+			name:     name,
+			wildcard: p[0] == '*',
+			kind:     kind,
+			matcher:  matcher,
+		})
+	}
 
-	r.roots[root] = append(r.roots[root], Route{
+	// Prefixes are stored without a trailing slash, so /users and /users/
+	// land on the same prefix and the root routes on the empty one
+	root := strings.TrimRight(strings.Join(rootParts, "/"), "/")
+	route := &Route{
 		prefix:    root,
 		partNames: varParts,
 		function:  f,
+		source:    source,
+	}
+	if err := r.insert(route, path); err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+/**
+@info Inserts a parsed route into the routes table, failing when an unconstrained route already matches the same paths
+@param {*Route} [route] The route to insert
+@param {string} [path] The path the route was registered with, for the conflict error
+@returns {error}
+*/
+func (r *Routes) insert(route *Route, path string) error {
+	routes := r.roots[route.prefix]
+	for _, rt := range routes {
+		if rt.unconstrained() && rt.sameShape(route) {
+			if route.source != "" && rt.source != "" {
+				return fmt.Errorf("%w: %s at %s is already registered as %s at %s", ErrRouteConflict, path, route.source, rt.template(), rt.source)
+			}
+			return fmt.Errorf("%w: %s is already registered as %s", ErrRouteConflict, path, rt.template())
+		}
+	}
+
+	// Wildcard routes are kept behind every other route sharing the prefix
+	// so that they are only tried once the more specific ones fail, and a
+	// typed param goes ahead of a plain one in the same position
+	index := len(routes)
+	if !route.isWildcard() {
+		for i, rt := range routes {
+			if rt.isWildcard() || route.narrows(rt) {
+				index = i
+				break
+			}
+		}
+	}
+	routes = append(routes, nil)
+	copy(routes[index+1:], routes[index:])
+	routes[index] = route
+	r.roots[route.prefix] = routes
+	return nil
+}
+
+/**
+@info Removes a route from the routes table, undoing its registration
+@param {*Route} [route] The route to remove
+*/
+func (r *Routes) remove(route *Route) {
+	var kept []*Route
+	for _, rt := range r.roots[route.prefix] {
+		if rt != route {
+			kept = append(kept, rt)
+		}
+	}
+	if len(kept) == 0 {
+		delete(r.roots, route.prefix)
+		return
+	}
+	r.roots[route.prefix] = kept
+}
+
+/**
+@info Whether the route only matches paths the other route matches too, because it types a param the other
+leaves plain. /files/:id|int narrows /files/:name
+@param {*Route} [o] The route to compare with
+@returns {bool}
+*/
+func (r *Route) narrows(o *Route) bool {
+	if r.prefix != o.prefix || len(r.partNames) != len(o.partNames) {
+		return false
+	}
+	narrower := false
+	for i, p := range r.partNames {
+		q := o.partNames[i]
+		switch {
+		case p.fixed != q.fixed || p.wildcard != q.wildcard || p.fixed && p.name != q.name:
+			return false
+		case p.kind == q.kind:
+		case q.kind == "":
+			narrower = true
+		default:
+			return false
+		}
+	}
+	return narrower
+}
+
+/**
+@info Whether the route matches on its path alone, without validators or match functions
+@returns {bool}
+*/
+func (r *Route) unconstrained() bool {
+	return len(r.validators) == 0 && len(r.matchers) == 0 && len(r.formats) == 0
+}
+
+/**
+@info Whether both routes match exactly the same paths, whatever their param names
+@param {*Route} [o] The route to compare with
+@returns {bool}
+*/
+func (r *Route) sameShape(o *Route) bool {
+	if r.prefix != o.prefix || len(r.partNames) != len(o.partNames) {
+		return false
+	}
+	for i, p := range r.partNames {
+		q := o.partNames[i]
+		if p.fixed != q.fixed || p.wildcard != q.wildcard || p.kind != q.kind || p.fixed && p.name != q.name {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+@info Whether the route ends with a catch-all wildcard segment
+@returns {bool}
+*/
+func (r *Route) isWildcard() bool {
+	return len(r.partNames) > 0 && r.partNames[len(r.partNames)-1].wildcard
+}
+
+/**
+@info Adds a validation callback for one of the route params, the route is skipped when it fails
+@param {string} [name] The param name to validate
+@param {func(string) bool} [validate] The callback deciding whether the value is acceptable
+@returns {*Route}
+*/
+func (r *Route) Validate(name string, validate func(string) bool) *Route {
+	if r.validators == nil {
+		r.validators = make(map[string]func(string) bool)
+	}
+	r.validators[name] = validate
+	return r
+}
+
+/**
+@info Adds a function the request has to satisfy for the route to match, routes sharing a path
+are tried in registration order so the unconstrained fallback should be registered last
+@param {func(*http.Request) bool} [match] The function deciding whether the request matches
+@returns {*Route}
+*/
+func (r *Route) MatchFunc(match func(*http.Request) bool) *Route {
+	r.matchers = append(r.matchers, match)
+	return r
+}
+
+/**
+@info Restricts the route to requests for the given host, the request port is ignored
+@param {string} [host] The hostname, IPv6 addresses can be given with or without brackets
+@returns {*Route}
+*/
+func (r *Route) Host(host string) *Route {
+	host = stripPort(host)
+	return r.MatchFunc(func(req *http.Request) bool {
+		return strings.EqualFold(stripPort(req.Host), host)
 	})
 }
 
+/**
+@info Routes requests by file extension, /data with Format("json", "xml") serves /data.json and /data.xml.
+The extension is stripped before matching and the handler reads it with Format, requests without one of
+the extensions don't match the route
+@param {...string} [exts] The extensions without the dot
+@returns {*Route}
+*/
+func (r *Route) Format(exts ...string) *Route {
+	r.formats = append(r.formats, exts...)
+	return r
+}
+
+/**
+@info Splits the extension off the last segment of a path, a leading dot like in /.well-known isn't one
+@param {string} [path] The path
+@returns {string, string} The path without the extension and the extension, empty when there's none
+*/
+func splitExt(path string) (string, string) {
+	path = strings.TrimRight(path, "/")
+	dot := strings.LastIndexByte(path, '.')
+	if dot <= strings.LastIndexByte(path, '/')+1 {
+		return path, ""
+	}
+	return path[:dot], path[dot+1:]
+}
+
+/**
+@info Gets the extension of a request path the route serves
+@param {string} [path] The request path
+@returns {string, bool} The extension, false when the route doesn't serve it
+*/
+func (r *Route) format(path string) (string, bool) {
+	_, ext := splitExt(path)
+	for _, format := range r.formats {
+		if ext != "" && format == ext {
+			return ext, true
+		}
+	}
+	return "", false
+}
+
+/**
+@info Strips the port and IPv6 brackets from a host
+@param {string} [host] The host, like example.com:8080 or [::1]:8080
+@returns {string}
+*/
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	// No port, only the brackets of a bare IPv6 address may be left
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+/**
+@info Runs the route match functions against the request
+@param {*http.Request} [req] The request to match, nil only matches unconstrained routes
+@returns {bool}
+*/
+func (r *Route) matches(req *http.Request) bool {
+	if req == nil {
+		return len(r.matchers) == 0
+	}
+	for _, match := range r.matchers {
+		if !match(req) {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+@info Names the route for listings and generated docs
+@param {string} [name] The route name
+@returns {*Route}
+*/
+func (r *Route) Name(name string) *Route {
+	r.name = name
+	return r
+}
+
+/**
+@info Takes over the modifiers of another route, keeping its own path, handler and hit counter. The
+middleware holding state, like RateLimit, is built again so the routes don't share it
+@param {*Route} [src] The route to copy the modifiers from
+*/
+func (r *Route) inherit(src *Route) {
+	r.deprecated, r.sunset = src.deprecated, src.sunset
+	r.greedy = src.greedy
+	r.name = src.name
+	r.cacheControl = src.cacheControl
+	r.source = src.source
+	r.status = src.status
+	r.method = src.method
+	r.healthCheck = src.healthCheck
+
+	r.validators = nil
+	for name, validate := range src.validators {
+		r.Validate(name, validate)
+	}
+	r.matchers = append([]func(*http.Request) bool(nil), src.matchers...)
+	r.middlewares = append([]func(http.Handler) http.Handler(nil), src.middlewares...)
+	r.stateful = nil
+	for i, build := range src.stateful {
+		if r.stateful == nil {
+			r.stateful = make(map[int]func() func(http.Handler) http.Handler)
+		}
+		r.stateful[i] = build
+		r.middlewares[i] = build()
+	}
+	r.stacks = append([]string(nil), src.stacks...)
+	r.queries = append([]string(nil), src.queries...)
+	r.tags = append([]string(nil), src.tags...)
+	r.formats = append([]string(nil), src.formats...)
+	if src.cors != nil {
+		r.CORS(*src.cors)
+	}
+	r.queryDefaults = nil
+	for name, def := range src.queryDefaults {
+		r.QueryDefault(name, def)
+	}
+}
+
+/**
+@info Rebuilds the path template the route was registered with
+@returns {string}
+*/
+func (r *Route) template() string {
+	parts := []string{r.prefix}
+	for _, p := range r.partNames {
+		switch {
+		case p.fixed:
+			parts = append(parts, p.name)
+		case p.wildcard:
+			parts = append(parts, "*"+p.name)
+		case p.kind != "":
+			parts = append(parts, ":"+p.name+"|"+p.kind)
+		default:
+			parts = append(parts, ":"+p.name)
+		}
+	}
+	if template := strings.Join(parts, "/"); template != "" {
+		return template
+	}
+	return "/"
+}
+
+/**
+@info Describes the route for the listings and diagnostics
+@param {string} [method] The method the route is registered under
+@returns {RouteInfo}
+*/
+func (r *Route) info(method string) RouteInfo {
+	return RouteInfo{
+		Method:     method,
+		Template:   r.template(),
+		ParamNames: r.paramNames(),
+		Name:       r.name,
+		Tags:       append([]string(nil), r.tags...),
+	}
+}
+
+/**
+@info Lists the names of the route params in path order
+@returns {[]string}
+*/
+func (r *Route) paramNames() []string {
+	var names []string
+	for _, p := range r.partNames {
+		if !p.fixed {
+			names = append(names, p.name)
+		}
+	}
+	return names
+}
+
+/**
+@info Calls fn for every route in the table, ordered by prefix then registration
+@param {func(*Route) error} [fn] The callback, returning an error stops the walk
+@returns {error}
+*/
+func (r *Routes) walk(fn func(route *Route) error) error {
+	prefixes := make([]string, 0, len(r.roots))
+	for prefix := range r.roots {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		for _, route := range r.roots[prefix] {
+			if err := fn(route); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/**
+@info Lets the last param absorb any excess path segments instead of the route missing,
+/users/:id then matches /users/123/extra with id set to 123/extra
+@returns {*Route}
+*/
+func (r *Route) Greedy() *Route {
+	r.greedy = true
+	return r
+}
+
+/**
+@info Whether the route ends with a param absorbing excess segments
+@returns {bool}
+*/
+func (r *Route) isGreedy() bool {
+	return r.greedy && len(r.partNames) > 0 && !r.partNames[len(r.partNames)-1].fixed
+}
+
+/**
+@info Marks the route as deprecated, responses carry the Deprecation and Sunset headers
+@param {time.Time} [sunset] The date the route goes away, zero leaves the Sunset header out
+@returns {*Route}
+*/
+func (r *Route) Deprecated(sunset time.Time) *Route {
+	r.deprecated = true
+	r.sunset = sunset
+	return r
+}
+
+/**
+@info Tags the route, for filtering the listings with Routes and Walk
+@param {...string} [tags] The tags, like public or v1
+@returns {*Route}
+*/
+func (r *Route) Tag(tags ...string) *Route {
+	for _, tag := range tags {
+		if !r.hasTags([]string{tag}) {
+			r.tags = append(r.tags, tag)
+		}
+	}
+	return r
+}
+
+/**
+@info Reports whether the route carries every given tag
+@param {[]string} [tags] The tags
+@returns {bool}
+*/
+func (r *Route) hasTags(tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, own := range r.tags {
+			if own == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+@info Marks the route as a health check, it keeps being served while the router is draining
+@returns {*Route}
+*/
+func (r *Route) HealthCheck() *Route {
+	r.healthCheck = true
+	return r
+}
+
+/**
+@info Wraps the route handler with middleware that only runs for this route
+@param {...func(http.Handler)http.Handler} [mw] The middleware stack to append
+@returns {*Route}
+*/
+func (r *Route) Middleware(mw ...func(http.Handler) http.Handler) *Route {
+	r.middlewares = append(r.middlewares, mw...)
+	return r
+}
+
+/**
+@info Declares query params the route requires, the router answers with a ValidationError listing
+the missing ones and the handler reads them with Query
+@param {...string} [names] The query param names
+@returns {*Route}
+*/
+func (r *Route) Queries(names ...string) *Route {
+	r.queries = append(r.queries, names...)
+	return r
+}
+
+/**
+@info Declares an optional query param of the route, the handler reads it with Query and gets
+the default when the request leaves it out or empty
+@param {string} [name] The query param name
+@param {string} [def] The default value
+@returns {*Route}
+*/
+func (r *Route) QueryDefault(name string, def string) *Route {
+	if r.queryDefaults == nil {
+		r.queryDefaults = make(map[string]string)
+	}
+	r.queryDefaults[name] = def
+	return r
+}
+
+/**
+@info Extracts the declared query params of the route from the request
+@param {*http.Request} [req] The net/http request instance
+@returns {map[string][]string, *ValidationError} The params, or the error listing every missing one
+*/
+func (r *Route) queryParams(req *http.Request) (map[string][]string, *ValidationError) {
+	if len(r.queries) == 0 && len(r.queryDefaults) == 0 {
+		return nil, nil
+	}
+	all := req.URL.Query()
+	query := make(map[string][]string, len(r.queries)+len(r.queryDefaults))
+	var missing ValidationError
+	for _, name := range r.queries {
+		values, ok := all[name]
+		if !ok {
+			missing.Add("query", name, "required")
+			continue
+		}
+		query[name] = values
+	}
+	if len(missing.Fields) > 0 {
+		return nil, &missing
+	}
+	for name, def := range r.queryDefaults {
+		if values := all[name]; len(values) > 0 && values[0] != "" {
+			query[name] = values
+		} else {
+			query[name] = []string{def}
+		}
+	}
+	return query, nil
+}
+
+/**
+@info Wraps the route handler with the router middleware stacks of the given names, resolved when the route matches
+@param {...string} [names] The stack names defined with Router.DefineStack
+@returns {*Route}
+*/
+func (r *Route) UseStack(names ...string) *Route {
+	r.stacks = append(r.stacks, names...)
+	return r
+}
+
+/**
+@info Limits each client IP to n requests per window on the route, answering 429 past that
+@param {int} [n] The requests allowed per window
+@param {time.Duration} [per] The window length
+@returns {*Route}
+*/
+func (r *Route) RateLimit(n int, per time.Duration) *Route {
+	return r.statefulMiddleware(func() func(http.Handler) http.Handler { return RateLimit(n, per) })
+}
+
+/**
+@info Runs at most n requests of the route at once, answering 503 past that
+@param {int} [n] The requests allowed at once
+@param {...ConcurrencyOptions} [opts] The options, set Wait to queue the requests past the limit for a while
+@returns {*Route}
+*/
+func (r *Route) MaxConcurrent(n int, opts ...ConcurrencyOptions) *Route {
+	return r.statefulMiddleware(func() func(http.Handler) http.Handler { return MaxConcurrent(n, opts...) })
+}
+
+/**
+@info Adds a middleware holding state, like a limiter, remembering how to build it again for copies of the route
+@param {func() func(http.Handler) http.Handler} [build] Builds the middleware with fresh state
+@returns {*Route}
+*/
+func (r *Route) statefulMiddleware(build func() func(http.Handler) http.Handler) *Route {
+	if r.stateful == nil {
+		r.stateful = make(map[int]func() func(http.Handler) http.Handler)
+	}
+	r.stateful[len(r.middlewares)] = build
+	return r.Middleware(build())
+}
+
+/**
+@info Gets the route handler wrapped with the route middleware
+@returns {http.Handler}
+*/
+func (r *Route) handler() http.Handler {
+	if r.status != 0 {
+		return chain(r.middlewares, withStatus(r.status, r.function))
+	}
+	return chain(r.middlewares, r.function)
+}
+
+/**
+@info Sets the status the route responds with when the handler doesn't write one
+@param {int} [status] The default status code, like 204
+@returns {*Route}
+*/
+func (r *Route) Status(status int) *Route {
+	r.status = status
+	return r
+}
+
+/**
+@info Sets the Cache-Control header on the route responses
+@param {string} [directive] The cache directive, like public, max-age=86400
+@returns {*Route}
+*/
+func (r *Route) CacheControl(directive string) *Route {
+	r.cacheControl = directive
+	return r
+}
+
+/**
+@info Sets the route specific response headers before the handler runs
+@param {http.ResponseWriter} [w] The net/http response instance
+*/
+func (r *Route) applyHeaders(w http.ResponseWriter) {
+	if r.cacheControl != "" {
+		w.Header().Set("Cache-Control", r.cacheControl)
+	}
+	if r.deprecated {
+		w.Header().Set("Deprecation", "true")
+		if !r.sunset.IsZero() {
+			w.Header().Set("Sunset", r.sunset.UTC().Format(http.TimeFormat))
+		}
+	}
+}
+
 /**
 @info Gets http.Handler and params from the routes table
 @param {string} [path] Path of the route to find
 @returns {http.Handler, map[string]string, bool}
 */
 func (r *Routes) Get(path string) (http.Handler, map[string]string, bool) {
-	var routes []Route
-	remaining := path
-	for {
-		var ok bool
-		routes, ok = r.roots[remaining]
-		if ok {
-			return matchRoutes(path, routes)
+	route, params, ok := r.find(path, nil)
+	if !ok {
+		return nil, nil, false
+	}
+	return route.function, params, true
+}
 
+/**
+@info Finds the matching route and its params from the routes table
+@param {string} [path] Path of the route to find
+@param {*http.Request} [req] The request for the route match functions, nil skips routes having them
+@returns {*Route, map[string]string, bool}
+*/
+func (r *Routes) find(path string, req *http.Request) (*Route, map[string]string, bool) {
+	remaining := strings.TrimRight(path, "/")
+	// The static routes serving the path with its extension stripped live
+	// under the stripped prefix, tried right after the full one. Only the
+	// routes using Format are considered there, the others never see the
+	// path without its extension
+	stripped, ext := splitExt(remaining)
+	for {
+		if routes, ok := r.roots[remaining]; ok {
+			if route, params, ok := matchRoutes(path, routes, req); ok {
+				return route, params, true
+			}
+		}
+		if ext != "" {
+			if routes := formatRoutes(r.roots[stripped]); len(routes) > 0 {
+				if route, params, ok := matchRoutes(path, routes, req); ok {
+					return route, params, true
+				}
+			}
+			ext = ""
 		}
 
-		if len(remaining) < 2 {
+		// Walk up to the next shorter prefix, finishing on the empty root
+		// where the root level routes live
+		if remaining == "" {
 			return nil, nil, false
 		}
 
@@ -92,49 +805,108 @@ func (r *Routes) Get(path string) (http.Handler, map[string]string, bool) {
 		if index < 0 {
 			return nil, nil, false
 		}
+		remaining = strings.TrimRight(remaining[:index], "/")
+	}
+}
 
-		if index > 0 {
-			remaining = remaining[:index]
-		} else {
-			remaining = "/"
+/**
+@info Picks the routes routing by file extension
+@param {[]*Route} [routes] The routes sharing a prefix
+@returns {[]*Route}
+*/
+func formatRoutes(routes []*Route) []*Route {
+	var formatted []*Route
+	for _, route := range routes {
+		if len(route.formats) > 0 {
+			formatted = append(formatted, route)
 		}
 	}
+	return formatted
 }
 
 /**
 @info Matches routes to the request
 @param {string} [path] Path of the request route to find
-@param {[]Route} [routes] The array of routes to match
-@returns {http.Handler, map[string]string, bool}
+@param {[]*Route} [routes] The array of routes to match
+@param {*http.Request} [req] The request for the route match functions
+@returns {*Route, map[string]string, bool}
 */
-func matchRoutes(path string, routes []Route) (http.Handler, map[string]string, bool) {
-outer:
-	for _, r := range routes {
-		params := strings.Split(
-			strings.TrimPrefix(
-				strings.TrimPrefix(path, r.prefix),
-				"/"),
-			"/")
-		valid := cleanArray(params)
-
-		if len(valid) == len(r.partNames) {
-			paramNames := make(map[string]string)
-			for i, p := range r.partNames {
-				if p.fixed {
-					if params[i] != p.name {
-						continue outer
-					} else {
-						continue
-					}
-				}
-				paramNames[p.name] = params[i]
+func matchRoutes(path string, routes []*Route, req *http.Request) (*Route, map[string]string, bool) {
+	// The catch all routes are tried in a second pass, so a request only lands on them once every
+	// more specific route failed its param types, validators or match functions. Greedy routes are
+	// only known after registration, which is why this isn't left to the insertion order alone
+	for _, catchAll := range [2]bool{false, true} {
+		for _, r := range routes {
+			if r.catchAll() != catchAll {
+				continue
+			}
+			if params, ok := r.match(path, req); ok {
+				return r, params, true
 			}
-			return r.function, paramNames, true
 		}
 	}
 	return nil, nil, false
 }
 
+/**
+@info Whether the route takes any number of trailing segments, like wildcard and greedy routes
+@returns {bool}
+*/
+func (r *Route) catchAll() bool {
+	return r.isWildcard() || r.isGreedy()
+}
+
+/**
+@info Matches the route against a request path, checking the param types, validators and match functions
+@param {string} [path] Path of the request
+@param {*http.Request} [req] The request for the route match functions
+@returns {map[string]string, bool} The path params, nil for static routes
+*/
+func (r *Route) match(path string, req *http.Request) (map[string]string, bool) {
+	if len(r.formats) > 0 {
+		if _, ok := r.format(path); !ok {
+			return nil, false
+		}
+		path, _ = splitExt(path)
+	}
+	if len(r.partNames) == 0 {
+		// Static routes only match their own prefix, skip the splitting and the params map
+		return nil, strings.TrimRight(path, "/") == r.prefix && r.matches(req)
+	}
+	// Empty segments are dropped, the positions below all index the non empty ones
+	valid := cleanArray(strings.Split(strings.TrimPrefix(path, r.prefix), "/"))
+	if len(valid) != len(r.partNames) && !(r.isWildcard() && len(valid) >= len(r.partNames)-1) && !(r.isGreedy() && len(valid) > len(r.partNames)) {
+		return nil, false
+	}
+
+	paramNames := make(map[string]string)
+	for i, p := range r.partNames {
+		if p.wildcard || r.isGreedy() && i == len(r.partNames)-1 {
+			paramNames[p.name] = strings.Join(valid[i:], "/")
+			break
+		}
+		if p.fixed {
+			if valid[i] != p.name {
+				return nil, false
+			}
+			continue
+		}
+		if p.matcher != nil && !p.matcher.MatchString(valid[i]) {
+			return nil, false
+		}
+		paramNames[p.name] = valid[i]
+	}
+	for name, validate := range r.validators {
+		if !validate(paramNames[name]) {
+			return nil, false
+		}
+	}
+	if !r.matches(req) {
+		return nil, false
+	}
+	return paramNames, true
+}
+
 /**
 @info Cleans the array and finds non nill values
 @param {string} [path] The array of string to slice and clean
@@ -148,4 +920,4 @@ func cleanArray(a []string) []string {
 		}
 	}
 	return valid
-}
\ No newline at end of file
+}
@@ -0,0 +1,222 @@
+package mux
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalPrefix(t *testing.T) {
+	tests := []struct {
+		path   string
+		prefix string
+		parts  []param
+	}{
+		{"/", "", nil},
+		{"/users", "/users", nil},
+		{"/users/", "/users", nil},
+		{"/users/:id", "/users", []param{{name: "id"}}},
+		{"/users/:id/", "/users", []param{{name: "id"}}},
+		{"/:id", "", []param{{name: "id"}}},
+	}
+	for _, tt := range tests {
+		route, err := NewRoutes().Add(tt.path, http.NotFoundHandler())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if route.prefix != tt.prefix {
+			t.Errorf("Add(%q) prefix = %q, want %q", tt.path, route.prefix, tt.prefix)
+		}
+		if !reflect.DeepEqual(route.partNames, tt.parts) {
+			t.Errorf("Add(%q) parts = %+v, want %+v", tt.path, route.partNames, tt.parts)
+		}
+	}
+}
+
+func TestTrailingSlashRegistration(t *testing.T) {
+	for _, registered := range []string{"/users", "/users/"} {
+		routes := NewRoutes()
+		routes.Add(registered, http.NotFoundHandler())
+		routes.Add(registered+"/:id", http.NotFoundHandler())
+		routes.Add("/", http.NotFoundHandler())
+
+		for _, path := range []string{"/users", "/users/"} {
+			if _, params, ok := routes.Get(path); !ok || len(params) != 0 {
+				t.Errorf("%q registration: Get(%q) = %v, %v", registered, path, params, ok)
+			}
+		}
+		for _, path := range []string{"/users/42", "/users/42/"} {
+			if _, params, ok := routes.Get(path); !ok || params["id"] != "42" {
+				t.Errorf("%q registration: Get(%q) = %v, %v", registered, path, params, ok)
+			}
+		}
+		if _, _, ok := routes.Get("/"); !ok {
+			t.Errorf("%q registration: root route missing", registered)
+		}
+	}
+}
+
+func TestParamTypes(t *testing.T) {
+	routes := NewRoutes()
+	for _, path := range []string{"/users/:id|int", "/posts/:slug|slug", "/orders/:uuid|uuid"} {
+		if _, err := routes.Add(path, http.NotFoundHandler()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]bool{
+		"/users/42":           true,
+		"/users/-7":           true,
+		"/users/4x2":          false,
+		"/users/abc":          false,
+		"/posts/hello-world":  true,
+		"/posts/hello--world": false,
+		"/posts/Hello":        false,
+		"/orders/1b4e28ba-2fa1-11d2-883f-0016d3cca427": true,
+		"/orders/1b4e28ba-2fa1-11d2-883f":              false,
+	}
+	for path, want := range tests {
+		if _, _, ok := routes.Get(path); ok != want {
+			t.Errorf("Get(%q) matched = %v, want %v", path, ok, want)
+		}
+	}
+
+	if _, err := routes.Add("/files/:name|filename", http.NotFoundHandler()); err == nil {
+		t.Error("Add accepted an unknown param type")
+	}
+}
+
+func TestAddErrors(t *testing.T) {
+	tests := []struct {
+		existing string
+		path     string
+		err      error
+	}{
+		{"", "users", ErrInvalidPath},
+		{"", "/files/*path/edit", ErrInvalidPath},
+		{"", "/users/:", ErrInvalidPath},
+		{"", "/users/:id|float", ErrInvalidPath},
+		{"", "/users/:id/posts/:id", ErrDuplicateParam},
+		{"/users/:id", "/users/:name", ErrRouteConflict},
+		{"/users/", "/users", ErrRouteConflict},
+		{"/users/:id", "/users/:id|int", nil},
+		{"/users/:id", "/users/:id/posts", nil},
+		{"/files/*path", "/files/:name", nil},
+	}
+	for _, tt := range tests {
+		routes := NewRoutes()
+		if tt.existing != "" {
+			if _, err := routes.Add(tt.existing, http.NotFoundHandler()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := routes.Add(tt.path, http.NotFoundHandler()); !errors.Is(err, tt.err) {
+			t.Errorf("Add(%q) after %q error = %v, want %v", tt.path, tt.existing, err, tt.err)
+		}
+	}
+
+	if err := NewRouter().Register("FETCH", "/", http.NotFoundHandler()); !errors.Is(err, ErrInvalidMethod) {
+		t.Errorf("Register with FETCH error = %v, want %v", err, ErrInvalidMethod)
+	}
+}
+
+func TestConstrainedRoutesDoNotConflict(t *testing.T) {
+	routes := NewRoutes()
+	route, _ := routes.Add("/users/:id", http.NotFoundHandler())
+	route.Validate("id", func(string) bool { return false })
+	if _, err := routes.Add("/users/:name", http.NotFoundHandler()); err != nil {
+		t.Errorf("Add after a constrained route: %v", err)
+	}
+}
+
+func TestStripPort(t *testing.T) {
+	tests := map[string]string{
+		"127.0.0.1":        "127.0.0.1",
+		"127.0.0.1:8080":   "127.0.0.1",
+		"[::1]":            "::1",
+		"[::1]:8080":       "::1",
+		"[2001:db8::1]:80": "2001:db8::1",
+		"example.com":      "example.com",
+		"example.com:443":  "example.com",
+	}
+	for host, want := range tests {
+		if got := stripPort(host); got != want {
+			t.Errorf("stripPort(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func benchmarkFind(b *testing.B, path string) {
+	routes := NewRoutes()
+	routes.Add("/health", http.NotFoundHandler())
+	routes.Add("/users/:id", http.NotFoundHandler())
+	routes.Add("/users/:id/posts", http.NotFoundHandler())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		routes.Get(path)
+	}
+}
+
+func BenchmarkFindStatic(b *testing.B) {
+	benchmarkFind(b, "/health")
+}
+
+func BenchmarkFindParam(b *testing.B) {
+	benchmarkFind(b, "/users/42")
+}
+
+func TestEmptySegments(t *testing.T) {
+	routes := NewRoutes()
+	routes.Add("/users/:id/posts/:pid", http.NotFoundHandler())
+	routes.Add("/teams/:team/members", http.NotFoundHandler())
+
+	tests := []struct {
+		path   string
+		params map[string]string
+	}{
+		{"/users//7/posts/3", map[string]string{"id": "7", "pid": "3"}},
+		{"/users/7//posts//3", map[string]string{"id": "7", "pid": "3"}},
+		{"/users/7/posts/3//", map[string]string{"id": "7", "pid": "3"}},
+		{"/teams//core//members", map[string]string{"team": "core"}},
+		{"/teams/core//posts", nil},
+		{"/users/7//3/posts", nil},
+	}
+	for _, tt := range tests {
+		_, params, ok := routes.Get(tt.path)
+		if ok != (tt.params != nil) || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("Get(%q) = %v, %v, want %v", tt.path, params, ok, tt.params)
+		}
+	}
+}
+
+func TestParamNames(t *testing.T) {
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{"/users/:id", true},
+		{"/users/:user_id", true},
+		{"/users/:user-id", true},
+		{"/users/:ID2", true},
+		{"/files/*file-path", true},
+		{"/users/:user.id", false},
+		{"/users/:user id", false},
+		{"/users/:üser", false},
+		{"/files/*path.ext", false},
+		{"/users/:id:name", false},
+	}
+	for _, tt := range tests {
+		_, err := NewRoutes().Add(tt.path, http.NotFoundHandler())
+		if tt.ok && err != nil || !tt.ok && !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Add(%q) error = %v, want ok %v", tt.path, err, tt.ok)
+		}
+	}
+
+	routes := NewRoutes()
+	routes.Add("/users/:user-id", http.NotFoundHandler())
+	if _, params, _ := routes.Get("/users/7"); params["user-id"] != "7" {
+		t.Errorf("expected user-id 7, got %v", params)
+	}
+}
@@ -0,0 +1,21 @@
+module github.com/gominima/mux/otelmux
+
+go 1.25.0
+
+require (
+	github.com/gominima/mux v0.0.0-20261016101902-bf5b0bbab59f
+	go.opentelemetry.io/otel v1.46.0
+	go.opentelemetry.io/otel/trace v1.46.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/go-logr/logr v1.4.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
+	go.opentelemetry.io/otel/metric v1.46.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
+
+// Builds against the checkout during development, modules importing this one ignore it
+replace github.com/gominima/mux => ../
@@ -0,0 +1,110 @@
+// Package otelmux traces the requests a mux router serves with OpenTelemetry. It's a module of
+// its own so the mux package stays free of the OpenTelemetry dependencies
+package otelmux
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/gominima/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/**
+@info Creates a middleware starting a server span for every request, named after the matched route
+template and continuing the trace context of the incoming headers. It uses the global tracer provider
+and propagator, and should be added with UseRaw so the route is already matched
+@param {string} [tracerName] The name of the tracer, usually the instrumented package
+@returns {func(http.Handler) http.Handler}
+*/
+func OTelMiddleware(tracerName string) func(http.Handler) http.Handler {
+	tracer := otel.Tracer(tracerName)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			route := mux.RouteTemplate(r)
+			name := route
+			if name == "" {
+				name = "HTTP " + r.Method
+			}
+			ctx, span := tracer.Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", route),
+				),
+			)
+			defer span.End()
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.response.status_code", sw.status))
+			if sw.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(sw.status))
+			}
+		})
+	}
+}
+
+// The response writer wrapper recording the status for the span
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+/**
+@info Records the status and writes it
+@param {int} [status] The status code
+*/
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wrote {
+		w.status, w.wrote = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+/**
+@info Writes the body, the status defaults to 200 like with net/http
+@param {[]byte} [b] The bytes to write
+@returns {int, error}
+*/
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+/**
+@info Flushes the wrapped response instance when it supports it
+*/
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wrote = true
+		f.Flush()
+	}
+}
+
+/**
+@info Hijacks the wrapped response instance connection when it supports it
+@returns {net.Conn, *bufio.ReadWriter, error}
+*/
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+/**
+@info Gets the wrapped response instance, for http.ResponseController
+@returns {http.ResponseWriter}
+*/
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
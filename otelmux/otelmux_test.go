@@ -0,0 +1,38 @@
+package otelmux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gominima/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestOTelMiddleware(t *testing.T) {
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	rt := mux.NewRouter()
+	rt.UseRaw(OTelMiddleware("test"))
+	var got trace.SpanContext
+	rt.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		got = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected the handler status to pass through, got %d", w.Code)
+	}
+	if got.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || !got.IsRemote() {
+		t.Errorf("expected the incoming trace context in the handler, got %+v", got)
+	}
+}
@@ -0,0 +1,127 @@
+package mux
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/**
+@info Decodes the path params of the matched route into the `param:"name"` tagged fields of a struct.
+Tagged fields are required unless the tag has the optional flag, like `param:"page,optional"`
+@param {*http.Request} [r] The net/http request instance
+@param {interface{}} [dst] A pointer to the struct to fill
+@returns {error} A *ValidationError listing every missing or malformed param
+*/
+func DecodeParams(r *http.Request, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode params: destination must be a pointer to a struct, got %T", dst)
+	}
+	v = v.Elem()
+	params := Params(r)
+
+	var verr ValidationError
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("param")
+		if !ok || field.PkgPath != "" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name, optional := opts[0], len(opts) > 1 && opts[1] == "optional"
+
+		value, ok := params[name]
+		if !ok || value == "" {
+			if !optional {
+				verr.Add("param", name, "required")
+			}
+			continue
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			verr.Add("param", name, err.Error())
+		}
+	}
+	return verr.Err()
+}
+
+/**
+@info Parses a string into a struct field of a basic kind
+@param {reflect.Value} [f] The settable field
+@param {string} [value] The raw value
+@returns {error}
+*/
+func setField(f reflect.Value, value string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected %s", f.Type())
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected %s", f.Type())
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, f.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected %s", f.Type())
+		}
+		f.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected %s", f.Type())
+		}
+		f.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
+
+/**
+@info Gets several path params of the matched route at once, failing when any of them is missing or empty
+@param {*http.Request} [r] The net/http request instance
+@param {...string} [names] The param names
+@returns {map[string]string, error} A *ValidationError listing every missing param
+*/
+func RequireParams(r *http.Request, names ...string) (map[string]string, error) {
+	params := Params(r)
+	values := make(map[string]string, len(names))
+	var missing ValidationError
+	for _, name := range names {
+		if value := params[name]; value != "" {
+			values[name] = value
+		} else {
+			missing.Add("param", name, "required")
+		}
+	}
+	if err := missing.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+/**
+@info Gets a path param of the matched route split on /, meant for wildcards like /tags/*tags where
+/tags/go/web gives [go web]. A plain param gives a single value
+@param {*http.Request} [r] The net/http request instance
+@param {string} [name] The param name
+@returns {[]string} nil when the param is missing or empty
+*/
+func ParamSlice(r *http.Request, name string) []string {
+	value := Params(r)[name]
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "/")
+}
@@ -0,0 +1,116 @@
+package mux
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeParams(t *testing.T) {
+	type post struct {
+		UserID  int     `param:"id"`
+		Slug    string  `param:"slug"`
+		Version uint8   `param:"version,optional"`
+		Score   float64 `param:"score,optional"`
+		Ignored string
+	}
+
+	var got post
+	var err error
+	rt := NewRouter()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		got = post{}
+		err = DecodeParams(r, &got)
+	}
+	rt.Get("/users/:id/posts/:slug", handler)
+	rt.Get("/v/:version/users/:id/posts/:slug", handler)
+
+	serve(rt, "GET", "/users/42/posts/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (post{UserID: 42, Slug: "hello"}); got != want {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+
+	serve(rt, "GET", "/v/2/users/42/posts/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (post{UserID: 42, Slug: "hello", Version: 2}); got != want {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+
+	serve(rt, "GET", "/users/abc/posts/hello")
+	if err == nil || !strings.Contains(err.Error(), "param id") {
+		t.Errorf("bad int error = %v", err)
+	}
+
+	serve(rt, "GET", "/v/300/users/42/posts/hello")
+	if err == nil || !strings.Contains(err.Error(), "param version") {
+		t.Errorf("overflowing uint8 error = %v", err)
+	}
+
+	var missing struct {
+		Name string `param:"name"`
+	}
+	rt.Get("/missing/:id", func(w http.ResponseWriter, r *http.Request) {
+		err = DecodeParams(r, &missing)
+	})
+	serve(rt, "GET", "/missing/1")
+	if err == nil || err.Error() != "validation failed: param name: required" {
+		t.Errorf("missing param error = %v", err)
+	}
+
+	if err := DecodeParams(&http.Request{}, missing); err == nil {
+		t.Error("DecodeParams accepted a non pointer destination")
+	}
+}
+
+func TestRequireParams(t *testing.T) {
+	var values map[string]string
+	var err error
+	rt := NewRouter()
+	rt.Get("/orgs/:org/repos/:repo", func(w http.ResponseWriter, r *http.Request) {
+		values, err = RequireParams(r, "org", "repo")
+	})
+	rt.Get("/orgs/:org", func(w http.ResponseWriter, r *http.Request) {
+		values, err = RequireParams(r, "org", "repo", "branch")
+	})
+
+	serve(rt, http.MethodGet, "/orgs/acme/repos/mux")
+	if err != nil || !reflect.DeepEqual(values, map[string]string{"org": "acme", "repo": "mux"}) {
+		t.Errorf("expected both params, got %v, %v", values, err)
+	}
+
+	serve(rt, http.MethodGet, "/orgs/acme")
+	if err == nil || err.Error() != "validation failed: param repo: required; param branch: required" || values != nil {
+		t.Errorf("expected the missing params to be listed, got %v, %v", values, err)
+	}
+}
+
+func TestParamSlice(t *testing.T) {
+	rt := NewRouter()
+	var got []string
+	rt.Get("/tags/*tags", func(w http.ResponseWriter, r *http.Request) {
+		got = ParamSlice(r, "tags")
+	})
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/tags", nil},
+		{"/tags/go", []string{"go"}},
+		{"/tags/go/web", []string{"go", "web"}},
+		{"/tags/go//web/http/", []string{"go", "web", "http"}},
+	}
+	for _, tt := range tests {
+		got = []string{"unset"}
+		serve(rt, http.MethodGet, tt.path)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GET %s: ParamSlice = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/gominima/mux/protomux
+
+go 1.25.0
+
+require (
+	github.com/gominima/mux v0.0.0-20261016101902-bb209613ede4
+	google.golang.org/protobuf v1.36.12
+)
+
+require golang.org/x/text v0.14.0 // indirect
+
+// Builds against the checkout during development, modules importing this one ignore it
+replace github.com/gominima/mux => ../
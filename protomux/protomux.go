@@ -0,0 +1,61 @@
+// Package protomux writes and reads protobuf bodies in mux handlers. It's a module of its own so the
+// mux package stays free of the protobuf dependency
+package protomux
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// The content type of protobuf bodies
+const ContentType = "application/x-protobuf"
+
+// Returned by BindProto when the request body isn't declared as protobuf
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+/**
+@info Writes a protobuf response, only the headers are sent when a GET route answers a HEAD request
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {int} [status] The response status code
+@param {proto.Message} [msg] The message to marshal
+@returns {error} The marshal error, nothing is written then
+*/
+func Proto(w http.ResponseWriter, status int, msg proto.Message) error {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("protomux: marshal %T: %w", msg, err)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+/**
+@info Reads the request body into a protobuf message. The body has to be declared as application/x-protobuf,
+application/protobuf is accepted too
+@param {*http.Request} [r] The net/http request instance
+@param {proto.Message} [msg] The message to unmarshal into
+@returns {error} wraps ErrUnsupportedMediaType when the content type doesn't match
+*/
+func BindProto(r *http.Request, msg proto.Message) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != ContentType && mediaType != "application/protobuf" {
+		return fmt.Errorf("protomux: %w %q, expected %s", ErrUnsupportedMediaType, r.Header.Get("Content-Type"), ContentType)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("protomux: read body: %w", err)
+	}
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return fmt.Errorf("protomux: unmarshal %T: %w", msg, err)
+	}
+	return nil
+}
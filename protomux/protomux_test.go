@@ -0,0 +1,71 @@
+package protomux
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gominima/mux"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	rt := mux.NewRouter()
+	rt.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+		var in wrapperspb.StringValue
+		if err := BindProto(r, &in); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrUnsupportedMediaType) {
+				status = http.StatusUnsupportedMediaType
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		Proto(w, http.StatusCreated, wrapperspb.String(strings.ToUpper(in.GetValue())))
+	})
+
+	body, err := proto.Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/echo", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentType)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /echo answered %d, want %d", w.Code, http.StatusCreated)
+	}
+	if got := w.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("Content-Type = %q, want %q", got, ContentType)
+	}
+	var out wrapperspb.StringValue
+	if err := proto.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.GetValue() != "HELLO" {
+		t.Errorf("echoed %q, want %q", out.GetValue(), "HELLO")
+	}
+
+	for _, contentType := range []string{"", "application/json", "application/x-protobuf-ish"} {
+		req := httptest.NewRequest("POST", "/echo", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("Content-Type %q answered %d, want %d", contentType, w.Code, http.StatusUnsupportedMediaType)
+		}
+	}
+
+	req = httptest.NewRequest("POST", "/echo", strings.NewReader("\xff\xff"))
+	req.Header.Set("Content-Type", "application/x-protobuf; proto=google.protobuf.StringValue")
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("malformed body answered %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
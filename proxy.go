@@ -0,0 +1,125 @@
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// The largest request body kept to retry on the next upstream, bigger requests only get one attempt
+const maxProxyRetryBody = 1 << 20
+
+/**
+@info Forwards every method under a prefix to a pool of upstreams taking turns, like
+rt.Proxy("/api", []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}). The prefix is stripped and
+the rest of the path appended to the upstream URL, the headers are passed through. When an upstream
+can't be reached the request moves on to the next one, as long as it has no body or a body under 1MB.
+Requests failing once sent are only tried again when their method is idempotent, so a POST is never sent twice
+@param {string} [prefix] The path prefix, it can hold params
+@param {[]string} [upstreams] The absolute upstream base URLs
+@returns {*Router}
+*/
+func (r *Router) Proxy(prefix string, upstreams []string) *Router {
+	if len(upstreams) == 0 {
+		panic("Minima: Proxy " + prefix + " needs at least one upstream")
+	}
+	pool := &upstreamPool{transport: http.DefaultTransport}
+	for _, upstream := range upstreams {
+		target, err := url.Parse(upstream)
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			panic("Minima: Proxy needs absolute upstream URLs, got " + upstream)
+		}
+		pool.targets = append(pool.targets, target)
+	}
+	proxy := &httputil.ReverseProxy{
+		// The upstream is only picked by the pool, once per attempt
+		Director:  func(*http.Request) {},
+		Transport: pool,
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			log.Printf("Minima: Proxy %s: %s", prefix, err)
+			r.writeError(w, http.StatusBadGateway, "Bad gateway")
+		},
+	}
+	return r.Handle(prefix, proxy)
+}
+
+/**
+ * @info The upstreams of a Proxy, used in turn as the transport of the reverse proxy
+ * @property {[]*url.URL} [targets] The upstream base URLs
+ * @property {uint32} [next] The number of requests sent so far, picking the next upstream
+ * @property {http.RoundTripper} [transport] The transport sending the requests
+ */
+type upstreamPool struct {
+	targets   []*url.URL
+	next      uint32
+	transport http.RoundTripper
+}
+
+/**
+@info Sends the request to the next upstream in turn, moving on to the following ones when it can't be reached,
+or when it failed and the method is idempotent
+@param {*http.Request} [req] The outgoing request, with the path relative to the upstream
+@returns {*http.Response, error}
+*/
+func (p *upstreamPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	retry := req.Body == nil || req.Body == http.NoBody
+	if !retry && req.ContentLength >= 0 && req.ContentLength <= maxProxyRetryBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		retry = true
+	}
+
+	idempotent := false
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		idempotent = true
+	}
+
+	start := int(atomic.AddUint32(&p.next, 1) - 1)
+	var err error
+	for i := range p.targets {
+		target := p.targets[(start+i)%len(p.targets)]
+		out := req.Clone(req.Context())
+		out.URL.Scheme, out.URL.Host = target.Scheme, target.Host
+		out.URL.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(req.URL.Path, "/")
+		out.URL.RawPath = ""
+		if target.RawQuery != "" && req.URL.RawQuery != "" {
+			out.URL.RawQuery = target.RawQuery + "&" + req.URL.RawQuery
+		} else {
+			out.URL.RawQuery = target.RawQuery + req.URL.RawQuery
+		}
+		if body != nil {
+			out.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		var res *http.Response
+		if res, err = p.transport.RoundTrip(out); err == nil {
+			return res, nil
+		}
+		if !retry || req.Context().Err() != nil || !(idempotent || unreached(err)) {
+			break
+		}
+	}
+	return nil, err
+}
+
+/**
+@info Reports whether a failed attempt never reached the upstream, the connection couldn't even be made
+@param {error} [err] The transport error
+@returns {bool}
+*/
+func unreached(err error) bool {
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
@@ -0,0 +1,99 @@
+package mux
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	upstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "%s %s %s?%s %s %s", name, r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("X-Token"), body)
+		}))
+	}
+	a, b := upstream("a"), upstream("b")
+	defer a.Close()
+	defer b.Close()
+
+	rt := NewRouter()
+	rt.Proxy("/api", []string{a.URL, b.URL + "/v2"})
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Token", "secret")
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+		want   string
+	}{
+		{"GET", "/api/users/1?full=1", "", "a GET /users/1?full=1 secret "},
+		{"GET", "/api/users/1", "", "b GET /v2/users/1? secret "},
+		{"POST", "/api/users", "ada", "a POST /users? secret ada"},
+		{"DELETE", "/api", "", "b DELETE /v2/? secret "},
+	}
+	for _, tt := range tests {
+		if got := send(tt.method, tt.target, tt.body).Body.String(); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.method, tt.target, got, tt.want)
+		}
+	}
+
+	// The closed upstream is skipped, its turns go to the other one
+	a.Close()
+	for i := 0; i < 2; i++ {
+		if got := send("POST", "/api/users", "bob").Body.String(); got != "b POST /v2/users? secret bob" {
+			t.Errorf("with a down, request %d = %q", i, got)
+		}
+	}
+	b.Close()
+	if w := send("GET", "/api/users", ""); w.Code != http.StatusBadGateway {
+		t.Errorf("with every upstream down answered %d, want %d", w.Code, http.StatusBadGateway)
+	}
+}
+
+func TestProxyRetriesOnlyIdempotent(t *testing.T) {
+	var hits [2]int32
+	// The first upstream reads the request then drops the connection without answering
+	a := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits[0], 1)
+		io.ReadAll(r.Body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits[1], 1)
+		w.Write([]byte("b"))
+	}))
+	defer a.Close()
+	defer b.Close()
+
+	rt := NewRouter()
+	rt.Proxy("/api", []string{a.URL, b.URL})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("POST", "/api/orders", strings.NewReader("order")))
+	if w.Code != http.StatusBadGateway || atomic.LoadInt32(&hits[0]) != 1 || atomic.LoadInt32(&hits[1]) != 0 {
+		t.Errorf("failed POST answered %d after %d and %d attempts, want 502 after a single one on a", w.Code, hits[0], hits[1])
+	}
+
+	// The next turn starts on b, the one after that fails on a and moves on to b
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/orders", nil))
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("PUT", "/api/orders/1", strings.NewReader("order")))
+	if w.Code != http.StatusOK || w.Body.String() != "b" || atomic.LoadInt32(&hits[0]) != 2 {
+		t.Errorf("failed PUT answered %d %q after %d attempts on a, want b's answer after a retry", w.Code, w.Body.String(), hits[0])
+	}
+}
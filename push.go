@@ -0,0 +1,24 @@
+package mux
+
+import "net/http"
+
+/**
+@info Pushes a resource to the client over HTTP/2 server push
+@param {http.ResponseWriter} [w] The net/http response instance the push goes through
+@param {string} [target] The path of the resource to push
+@param {*http.PushOptions} [opts] The push options, nil uses the defaults
+@returns {error} http.ErrNotSupported when the connection can't push
+*/
+func Push(w http.ResponseWriter, target string, opts *http.PushOptions) error {
+	for {
+		if pusher, ok := w.(http.Pusher); ok {
+			return pusher.Push(target, opts)
+		}
+		// Look through wrappers following the http.ResponseController convention
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return http.ErrNotSupported
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,40 @@
+package mux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type pushWriter struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (p *pushWriter) Push(target string, opts *http.PushOptions) error {
+	p.pushed = append(p.pushed, target)
+	return nil
+}
+
+type unwrapWriter struct {
+	http.ResponseWriter
+}
+
+func (u unwrapWriter) Unwrap() http.ResponseWriter {
+	return u.ResponseWriter
+}
+
+func TestPush(t *testing.T) {
+	p := &pushWriter{ResponseRecorder: httptest.NewRecorder()}
+	if err := Push(unwrapWriter{p}, "/app.css", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.pushed) != 1 || p.pushed[0] != "/app.css" {
+		t.Errorf("pushed %v, want [/app.css]", p.pushed)
+	}
+
+	if err := Push(httptest.NewRecorder(), "/app.css", nil); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Push without pusher returned %v, want %v", err, http.ErrNotSupported)
+	}
+}
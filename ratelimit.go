@@ -0,0 +1,95 @@
+package mux
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**
+ * @info A fixed window request counter per client IP
+ * @property {int} [limit] The requests allowed per window
+ * @property {time.Duration} [window] The window length
+ * @property {map[string]*rateWindow} [clients] The current window of each client
+ * @property {time.Time} [swept] When the expired windows were last dropped
+ * @property {sync.Mutex} [mu] Guards the clients
+ */
+type rateLimiter struct {
+	limit   int
+	window  time.Duration
+	clients map[string]*rateWindow
+	swept   time.Time
+	mu      sync.Mutex
+}
+
+// The requests a client made in its current window
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+/**
+@info Counts a request of a client
+@param {string} [client] The client key
+@param {time.Time} [now] The request time
+@returns {time.Duration, bool} How long until the window resets and whether the request is allowed
+*/
+func (l *rateLimiter) allow(client string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Drop the expired windows once per window length, so the memory is bounded
+	// by the clients seen in the last two windows
+	if now.Sub(l.swept) >= l.window {
+		for key, w := range l.clients {
+			if !now.Before(w.reset) {
+				delete(l.clients, key)
+			}
+		}
+		l.swept = now
+	}
+
+	w, ok := l.clients[client]
+	if !ok || !now.Before(w.reset) {
+		w = &rateWindow{reset: now.Add(l.window)}
+		l.clients[client] = w
+	}
+	w.count++
+	return w.reset.Sub(now), w.count <= l.limit
+}
+
+/**
+@info Creates a middleware allowing each client IP n requests per window, answering 429 with a
+Retry-After header past that. Each call makes its own counters, so wrapping several routes with the
+same middleware value makes them share the limit
+@param {int} [n] The requests allowed per window
+@param {time.Duration} [per] The window length
+@returns {func(http.Handler) http.Handler}
+*/
+func RateLimit(n int, per time.Duration) func(http.Handler) http.Handler {
+	l := &rateLimiter{limit: n, window: per, clients: make(map[string]*rateWindow)}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			retry, ok := l.allow(clientIP(r), time.Now())
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int((retry+time.Second-1)/time.Second)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+/**
+@info Gets the IP of the client connection, without the port
+@param {*http.Request} [r] The net/http request instance
+@returns {string}
+*/
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,61 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	rt := NewRouter()
+	rt.Post("/login", write("welcome")).RateLimit(3, time.Minute)
+	rt.Get("/home", write("home"))
+
+	login := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < 3; i++ {
+		if w := login("10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+	w := login("10.0.0.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("expected Retry-After 60, got %q", got)
+	}
+	if w := login("10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected another client to get through, got %d", w.Code)
+	}
+	for i := 0; i < 5; i++ {
+		if w := serve(rt, http.MethodGet, "/home"); w.Code != http.StatusOK {
+			t.Errorf("expected other routes to be unlimited, got %d", w.Code)
+		}
+	}
+}
+
+func TestRateLimiterWindows(t *testing.T) {
+	l := &rateLimiter{limit: 1, window: time.Second, clients: make(map[string]*rateWindow)}
+	now := time.Now()
+	if _, ok := l.allow("a", now); !ok {
+		t.Error("expected the first request to be allowed")
+	}
+	if _, ok := l.allow("a", now.Add(500*time.Millisecond)); ok {
+		t.Error("expected the second request in the window to be limited")
+	}
+	if _, ok := l.allow("a", now.Add(time.Second)); !ok {
+		t.Error("expected a new window to allow the request")
+	}
+	l.allow("b", now)
+	l.allow("c", now.Add(3*time.Second))
+	if len(l.clients) != 1 {
+		t.Errorf("expected the expired windows to be dropped, got %d clients", len(l.clients))
+	}
+}
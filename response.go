@@ -0,0 +1,180 @@
+package mux
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The body format of the router's built-in responses
+type ResponseFormat int
+
+const (
+	// Plain text bodies, the default
+	TextFormat ResponseFormat = iota
+	// {"error": "..."} JSON bodies
+	JSONFormat
+)
+
+/**
+@info Writes one of the router's built-in error responses in the configured format,
+every built-in response body goes through here
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {int} [status] The response status code
+@param {string} [message] The error message
+*/
+func (r *Router) writeError(w http.ResponseWriter, status int, message string) {
+	if r.DefaultResponseFormat != JSONFormat {
+		http.Error(w, message, status)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	JSON(w, status, map[string]string{"error": message})
+}
+
+/**
+@info Writes the built-in 404 response
+@param {http.ResponseWriter} [w] The net/http response instance
+*/
+func (r *Router) notFound(w http.ResponseWriter) {
+	r.mu.RLock()
+	message := r.notFoundMessage
+	r.mu.RUnlock()
+	r.writeError(w, http.StatusNotFound, message)
+}
+
+// The JSON bodies up to this size are sent with their Content-Length, larger ones are streamed
+const maxBufferedJSON = 64 << 10
+
+/**
+ * @info The writer JSON encodes into, it holds the body back until it outgrows maxBufferedJSON
+ * @property {http.ResponseWriter} [w] The net/http response instance
+ * @property {int} [status] The response status code, sent once the body is streamed
+ * @property {bytes.Buffer} [buf] The body held back
+ * @property {bool} [streaming] Whether the header is sent and the body goes straight to w
+ */
+type jsonWriter struct {
+	w         http.ResponseWriter
+	status    int
+	buf       bytes.Buffer
+	streaming bool
+}
+
+/**
+@info Buffers the body bytes, switching to streaming them once the buffer would outgrow maxBufferedJSON
+@param {[]byte} [b] The body bytes
+@returns {int, error}
+*/
+func (j *jsonWriter) Write(b []byte) (int, error) {
+	if !j.streaming && j.buf.Len()+len(b) <= maxBufferedJSON {
+		return j.buf.Write(b)
+	}
+	if !j.streaming {
+		j.streaming = true
+		j.w.WriteHeader(j.status)
+		if _, err := j.w.Write(j.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		j.buf.Reset()
+	}
+	return j.w.Write(b)
+}
+
+/**
+@info Writes a JSON response, the encoding is skipped when a GET route answers a HEAD request. Bodies up to
+64KB are sent with their Content-Length, larger ones are streamed without it. The value is encoded before
+anything is sent, so nothing is written when the encoding fails
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {int} [status] The response status code
+@param {interface{}} [v] The value to encode
+@returns {error}
+*/
+func JSON(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if isHead(w) {
+		w.WriteHeader(status)
+		return nil
+	}
+	jw := &jsonWriter{w: w, status: status}
+	if err := json.NewEncoder(jw).Encode(v); err != nil || jw.streaming {
+		return err
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(jw.buf.Len()))
+	w.WriteHeader(status)
+	_, err := w.Write(jw.buf.Bytes())
+	return err
+}
+
+/**
+@info Writes a plain text response, only the headers are sent when a GET route answers a HEAD request
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {int} [status] The response status code
+@param {string} [body] The response body
+@returns {error}
+*/
+func Text(w http.ResponseWriter, status int, body string) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if isHead(w) {
+		return nil
+	}
+	_, err := io.WriteString(w, body)
+	return err
+}
+
+/**
+@info Adds a request header to the Vary response header, keeping the fields already there and
+skipping ones already listed, for middleware whose response depends on a request header
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {string} [field] The request header name, like Accept-Encoding
+*/
+func AddVary(w http.ResponseWriter, field string) {
+	var fields []string
+	for _, value := range w.Header().Values("Vary") {
+		for _, f := range strings.Split(value, ",") {
+			f = strings.TrimSpace(f)
+			if f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+			if f != "" {
+				fields = append(fields, f)
+			}
+		}
+	}
+	w.Header().Set("Vary", strings.Join(append(fields, field), ", "))
+}
+
+/**
+@info Sets Last-Modified and answers 304 when the client copy is current according to If-Modified-Since,
+so GET and HEAD handlers can return early. Times are compared to the second, the precision of the header,
+and If-None-Match takes precedence so the check is skipped when the request has one
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {*http.Request} [r] The net/http request instance
+@param {time.Time} [modtime] When the content last changed, zero skips the check and the header
+@returns {bool} true when the 304 was written
+*/
+func NotModifiedSince(w http.ResponseWriter, r *http.Request, modtime time.Time) bool {
+	if modtime.IsZero() || modtime.Equal(time.Unix(0, 0)) {
+		return false
+	}
+	modtime = modtime.Truncate(time.Second)
+	w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modtime.After(since) {
+		return false
+	}
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -1,32 +1,156 @@
 package mux
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
 )
 
+type Handler func(w http.ResponseWriter, r *http.Request)
+
+// The seconds draining routers ask clients to wait before retrying
+const drainRetryAfter = 5
 
-type Param struct {
-	path string
-	param map[string]string
-} 
+// The methods the router serves
+var methods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS", "HEAD"}
 
-type Handler func(w http.ResponseWriter, r *http.Request)
+// Anything routes can be registered on, so libraries can attach their routes without depending on *Router
+type Registrar interface {
+	Register(method string, path string, handler http.Handler) error
+}
+
+var _ Registrar = (*Router)(nil)
+
+/**
+ * @info The structured description of a registered route
+ * @property {string} [Method] The route method
+ * @property {string} [Template] The path template, like /users/:id
+ * @property {[]string} [ParamNames] The names of the path params in order
+ * @property {string} [Name] The route name, if any
+ * @property {[]string} [Tags] The route tags, if any
+ */
+type RouteInfo struct {
+	Method     string
+	Template   string
+	ParamNames []string
+	Name       string
+	Tags       []string
+}
+
+/**
+@info Serves the request so Handler satisfies http.Handler
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {http.Request} [r] The net/http request instance
+*/
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h(w, r)
+}
 
 /**
- * @info The router structure
+@info Converts a net/http handler func into a Handler
+@param {http.HandlerFunc} [h] The net/http handler func to convert
+@returns {Handler}
+*/
+func FromHTTP(h http.HandlerFunc) Handler {
+	return Handler(h)
+}
+
+/**
+ * @info The router structure. Routes, route modifiers, method middleware and stacks can be added
+ * while it serves requests, the router lock guards them. Global middleware has to be added with UseRaw
+ * before the first route, and the exported fields are read without the lock so they're set before serving
  * @property {map[string][]*Routes} [routes] The mux routes
  * @property {Handler} [notfound] The handler for the non matching routes
  * @property {[]Handler} [minmiddleware] The minima handler middleware stack
  * @property {[]func(http.Handler)http.Handler} [middleware] The http.Handler middleware stack
+ * @property {map[string][]func(http.Handler)http.Handler} [methodMiddlewares] The middleware wrapping the handlers of each method, "*" for all of them
  * @property {http.Handler} [handler] The single http.Handler built on chaining the whole middleware stack
+ * @property {int32} [hasMiddleware] 1 once handler is built with global middleware in it, read without the lock
+ * @property {http.Handler} [fallback] The handler serving requests no route matched
+ * @property {string} [notFoundMessage] The body of the built-in 404 response
+ * @property {string} [basePath] The path prefix stripped from every request before matching
+ * @property {string} [defaultVersion] The version prefix tried for requests without one
+ * @property {http.Header} [headers] The headers set on every response unless the handler sets them
+ * @property {http.Header} [forcedHeaders] The headers set on every response, replacing what the handler set
+ * @property {func(http.ResponseWriter, *http.Request, []string)} [methodNotAllowed] The custom 405 response writer
+ * @property {map[string][]func(http.Handler)http.Handler} [stacks] The named middleware stacks routes opt into
+ * @property {map[string]bool} [audited] The templates and names of the routes whose responses are audited
+ * @property {bool} [frozen] Whether Freeze was called, the routes and middleware can't change anymore
+ * @property {[]func(*http.Request)} [beforeHooks] The callbacks run once the route of a request is looked up
+ * @property {[]func(*http.Request, int)} [afterHooks] The callbacks run with the status of every response
+ * @property {sync.RWMutex} [mu] Guards the routes and middleware against concurrent registration and serving
+ * @property {int} [MaxPathSegments] The maximum number of path segments served before answering 414, 0 disables the check
+ * @property {int} [MaxURLLength] The maximum request URL length in bytes served before answering 414, 0 disables the check
+ * @property {int} [MaxHeaderBytes] The maximum size of the request headers served before answering 431, 0 disables the check
+ * @property {int} [MaxBodyBytes] The maximum request body size, larger declared bodies get 413 and reads past it fail, 0 disables the check
+ * @property {bool} [StrictMethods] Routes methods exactly as sent instead of upper casing them and treating an empty one as GET
+ * @property {bool} [Debug] Records where each route is registered so conflict errors can point at both sites
+ * @property {ResponseFormat} [DefaultResponseFormat] The body format of the built-in error responses
+ * @property {func(RouteInfo, []byte)} [AuditSink] Receives the response bodies of the routes passed to AuditResponses
+ * @property {int} [MaxAuditBytes] The maximum response bytes captured for the AuditSink, 0 uses 64KB
+ * @property {bool} [RedirectTrailingSlash] Redirects paths with a trailing slash to the path without it
+ * @property {bool} [RedirectCleanPath] Redirects paths with empty, . or .. segments to their cleaned form
+ * @property {int} [RedirectCode] The status of the canonicalization redirects, 0 or a status that isn't 3xx uses 301 for GET and HEAD and 308 otherwise
+ * @property {bool} [TrackHits] Counts the requests each route serves, for UnusedRoutes
+ * @property {bool} [NormalizeUnicode] Normalizes the request paths, and the paths registered after it's set, to Unicode NFC before matching
+ * @property {bool} [UseEncodedPath] Matches the escaped request path so an encoded / stays inside its param, the params are unescaped after matching
+ * @property {bool} [DisableMIMESniffing] Sends X-Content-Type-Options: nosniff on every response so browsers stick to the declared Content-Type
+ * @property {func(http.ResponseWriter, *http.Request, *ValidationError)} [ValidationRenderer] Writes the validation errors, nil uses ValidationJSON
  */
 type Router struct {
-	handler       http.Handler
-	middlewares   []func(http.Handler) http.Handler
-	params []Param
-	routes        map[string]*Routes
+	MaxPathSegments       int
+	MaxURLLength          int
+	MaxHeaderBytes        int
+	MaxBodyBytes          int
+	DefaultResponseFormat ResponseFormat
+	Debug                 bool
+	StrictMethods         bool
+	AuditSink             func(RouteInfo, []byte)
+	MaxAuditBytes         int
+	RedirectTrailingSlash bool
+	RedirectCleanPath     bool
+	RedirectCode          int
+	TrackHits             bool
+	UseEncodedPath        bool
+	NormalizeUnicode      bool
+	DisableMIMESniffing   bool
+	ValidationRenderer    func(http.ResponseWriter, *http.Request, *ValidationError)
+	handler               http.Handler
+	middlewares           []func(http.Handler) http.Handler
+	routes                map[string]*Routes
+	basePath              string
+	notFoundMessage       string
+	fallback              http.Handler
+	methodMiddlewares     map[string][]func(http.Handler) http.Handler
+	stacks                map[string][]func(http.Handler) http.Handler
+	mu                    sync.RWMutex
+	audited               map[string]bool
+	defaultVersion        string
+	methodNotAllowed      func(http.ResponseWriter, *http.Request, []string)
+	headers               http.Header
+	forcedHeaders         http.Header
+	draining              int32
+	hasMiddleware         int32
+	chains                map[*Route]*routeChain
+	chainsMu              sync.RWMutex
+	base                  context.Context
+	cancelBase            context.CancelFunc
+	frozen                bool
+	beforeHooks           []func(*http.Request)
+	afterHooks            []func(*http.Request, int)
 }
 
 /**
@@ -35,112 +159,351 @@ return {Router}
 */
 func NewRouter() *Router {
 	return &Router{
-		routes: map[string]*Routes{
-			"GET":     NewRoutes(),
-			"POST":    NewRoutes(),
-			"PUT":     NewRoutes(),
-			"DELETE":  NewRoutes(),
-			"PATCH":   NewRoutes(),
-			"OPTIONS": NewRoutes(),
-			"HEAD":    NewRoutes(),
-		},
-		params: make([]Param, 0),
+		routes:          newRouteTables(),
+		notFoundMessage: "No matching route found",
+		MaxPathSegments: 100,
+	}
+}
+
+/**
+@info Makes the empty routes tables for every supported method
+@returns {map[string]*Routes}
+*/
+func newRouteTables() map[string]*Routes {
+	tables := make(map[string]*Routes, len(methods))
+	for _, method := range methods {
+		tables[method] = NewRoutes()
 	}
+	return tables
 }
 
 /**
-@info Registers a new route to router interface
+@info Clears every registered route, middleware and fallback handler so the router can be reused, a frozen
+router can be set up again. The configuration (exported fields, base path and not found message) is kept
+*/
+func (r *Router) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frozen = false
+	r.routes = newRouteTables()
+	r.middlewares = nil
+	r.methodMiddlewares = nil
+	r.stacks = nil
+	r.audited = nil
+	r.handler = nil
+	atomic.StoreInt32(&r.hasMiddleware, 0)
+	r.fallback = nil
+	r.methodNotAllowed = nil
+	r.beforeHooks = nil
+	r.afterHooks = nil
+	r.invalidateChains()
+}
+
+/**
+@info Registers a new route to router interface, it's safe to call while the router serves requests
 @param {string} [path] The route path
 return {string, []string}
 */
 func (r *Router) Register(method string, path string, handler http.Handler) error {
+	_, err := r.register(method, path, handler)
+	return err
+}
+
+/**
+@info Turns the draining mode on or off. While draining, new requests get a 503 with Retry-After
+except for the routes marked with HealthCheck, so the in-flight ones can finish before shutting down
+@param {bool} [on] Whether the router is draining
+*/
+func (r *Router) Draining(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&r.draining, v)
+}
+
+/**
+@info Registers a route built with NewRoute under its method. The *Route modifiers can only be called
+before, once registered the route is served under the router lock, so it's changed through the returned
+RouteBuilder like the routes of Get and the other methods
+@param {*Route} [route] The route to register
+@returns {*RouteBuilder, error}
+*/
+func (r *Router) AddRoute(route *Route) (*RouteBuilder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.frozen {
+		return nil, fmt.Errorf("%w: can't add %s %s", ErrRouterFrozen, route.method, route.template())
+	}
+	if r.handler == nil {
+		r.buildHandler()
+	}
+	routes, ok := r.routes[route.method]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrInvalidMethod, route.method)
+	}
+	if r.Debug {
+		route.source = callerSource()
+	}
+	if err := routes.insert(route, route.template()); err != nil {
+		return nil, err
+	}
+	return &RouteBuilder{Router: r, route: route}, nil
+}
+
+/**
+@info Registers a new route, returning it for the route modifiers
+@param {string} [method] The route method
+@param {string} [path] The route path
+@param {http.Handler} [handler] The handler for the given route
+@returns {*Route, error}
+*/
+func (r *Router) register(method string, path string, handler http.Handler) (*Route, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.registerLocked(method, path, handler)
+}
+
+/**
+@info Registers a new route with the router lock already held
+@param {string} [method] The route method
+@param {string} [path] The route path
+@param {http.Handler} [handler] The handler for the given route
+@returns {*Route, error}
+*/
+func (r *Router) registerLocked(method string, path string, handler http.Handler) (*Route, error) {
+	if r.frozen {
+		return nil, fmt.Errorf("%w: can't register %s %s", ErrRouterFrozen, method, path)
+	}
 	if r.handler == nil {
 		r.buildHandler()
 	}
 	routes, ok := r.routes[method]
 	if !ok {
-		return fmt.Errorf("method %s not valid", method)
+		return nil, fmt.Errorf("%w %s", ErrInvalidMethod, method)
+	}
+	if r.NormalizeUnicode {
+		path = norm.NFC.String(path)
 	}
 
-	routes.Add(path, handler)
-	return nil
+	var source string
+	if r.Debug {
+		source = callerSource()
+	}
+	return routes.add(path, handler, source)
+}
+
+/**
+@info Finds the first caller outside of this package, for the registration diagnostics
+@returns {string} The file:line of the caller
+*/
+func callerSource() string {
+	_, self, _, _ := runtime.Caller(0)
+	dir := filepath.Dir(self)
+
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) != dir || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
 }
 
+/**
+@info Registers a route and panics when the route is invalid, for the method helpers
+@param {string} [method] The route method
+@param {string} [path] The route path
+@param {http.Handler} [handler] The handler for the given route
+@returns {*Route}
+*/
+func (r *Router) mustRegister(method string, path string, handler http.Handler) *Route {
+	route, err := r.register(method, path, handler)
+	if err != nil {
+		panic("Minima: " + err.Error())
+	}
+	return route
+}
 
 /**
 @info Adds route with Get method
 @param {string} [path] The route path
 @param {...Handler} [handler] The handler for the given route
-@returns {*Router}
+@returns {*RouteBuilder} The builder for the route modifiers
 */
-func (r *Router) Get(path string, handler Handler) *Router {
-	r.Register("GET", path, http.HandlerFunc(handler))
-	return r
+func (r *Router) Get(path string, handler Handler) *RouteBuilder {
+	return &RouteBuilder{Router: r, route: r.mustRegister("GET", path, http.HandlerFunc(handler))}
 }
 
 /**
 @info Adds route with Post method
 @param {string} [path] The route path
 @param {...Handler} [handler] The handler for the given route
-@returns {*Router}
+@returns {*RouteBuilder} The builder for the route modifiers
 */
-func (r *Router) Post(path string, handler Handler) *Router {
-	r.Register("POST", path, http.HandlerFunc(handler))
-	return r
+func (r *Router) Post(path string, handler Handler) *RouteBuilder {
+	return &RouteBuilder{Router: r, route: r.mustRegister("POST", path, http.HandlerFunc(handler))}
 }
 
 /**
 @info Adds route with Put method
 @param {string} [path] The route path
 @param {...Handler} [handler] The handler for the given route
-@returns {*Router}
+@returns {*RouteBuilder} The builder for the route modifiers
 */
-func (r *Router) Put(path string, handler Handler) *Router {
-	r.Register("PUT", path,  http.HandlerFunc(handler))
-	return r
+func (r *Router) Put(path string, handler Handler) *RouteBuilder {
+	return &RouteBuilder{Router: r, route: r.mustRegister("PUT", path, http.HandlerFunc(handler))}
 }
 
 /**
 @info Adds route with Patch method
 @param {string} [path] The route path
 @param {...Handler} [handler] The handler for the given route
-@returns {*Router}
+@returns {*RouteBuilder} The builder for the route modifiers
 */
-func (r *Router) Patch(path string, handler Handler) {
-	r.Register("PATCH", path,  http.HandlerFunc(handler))
+func (r *Router) Patch(path string, handler Handler) *RouteBuilder {
+	return &RouteBuilder{Router: r, route: r.mustRegister("PATCH", path, http.HandlerFunc(handler))}
 }
 
 /**
 @info Adds route with Options method
 @param {string} [path] The route path
 @param {...Handler} [handler] The handler for the given route
-@returns {*Router}
+@returns {*RouteBuilder} The builder for the route modifiers
 */
-func (r *Router) Options(path string, handler Handler) *Router {
-	r.Register("OPTIONS", path,  http.HandlerFunc(handler))
-	return r
+func (r *Router) Options(path string, handler Handler) *RouteBuilder {
+	return &RouteBuilder{Router: r, route: r.mustRegister("OPTIONS", path, http.HandlerFunc(handler))}
 }
 
 /**
 @info Adds route with Head method
 @param {string} [path] The route path
 @param {...Handler} [handler] The handler for the given route
-@returns {*Router}
+@returns {*RouteBuilder} The builder for the route modifiers
 */
-func (r *Router) Head(path string, handler Handler) *Router {
-	r.Register("HEAD", path,  http.HandlerFunc(handler))
-	return r
+func (r *Router) Head(path string, handler Handler) *RouteBuilder {
+	return &RouteBuilder{Router: r, route: r.mustRegister("HEAD", path, http.HandlerFunc(handler))}
 }
 
 /**
 @info Adds route with Delete method
 @param {string} [path] The route path
 @param {...Handler} [handler] The handler for the given route
-@returns {*Router}
+@returns {*RouteBuilder} The builder for the route modifiers
 */
-func (r *Router) Delete(path string, handler Handler) *Router {
-	r.Register("DELETE", path,  http.HandlerFunc(handler))
-	return r
+func (r *Router) Delete(path string, handler Handler) *RouteBuilder {
+	return &RouteBuilder{Router: r, route: r.mustRegister("DELETE", path, http.HandlerFunc(handler))}
+}
+
+/**
+@info Registers routes conditionally, rt.When(devMode).Get("/debug", h) only registers the route when devMode is set
+@param {bool} [cond] Whether the routes chained on the result are registered
+@returns {*Router} The router itself, or a detached one that nothing is served from when cond is false
+*/
+func (r *Router) When(cond bool) *Router {
+	if cond {
+		return r
+	}
+	return NewRouter()
+}
+
+/**
+@info Calls fn for every registered route, ordered by method then path prefix
+@param {func(method string, template string, handler http.Handler) error} [fn] The callback, returning an error stops the walk
+@param {...string} [tags] Only walks the routes carrying all of these tags
+@returns {error}
+*/
+func (r *Router) Walk(fn func(method string, template string, handler http.Handler) error, tags ...string) error {
+	return r.walk(func(method string, route *Route) error {
+		r.mu.RLock()
+		tagged, template, handler := route.hasTags(tags), route.template(), route.function
+		r.mu.RUnlock()
+		if !tagged {
+			return nil
+		}
+		return fn(method, template, handler)
+	})
+}
+
+/**
+@info Calls fn for every registered route with the route itself. The routes are listed under the lock
+and fn runs without it, so it can register routes and apply modifiers
+@param {func(string, *Route) error} [fn] The callback, returning an error stops the walk
+@returns {error}
+*/
+func (r *Router) walk(fn func(method string, route *Route) error) error {
+	type methodRoute struct {
+		method string
+		route  *Route
+	}
+	var routes []methodRoute
+	r.mu.RLock()
+	methods := make([]string, 0, len(r.routes))
+	for method := range r.routes {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		r.routes[method].walk(func(route *Route) error {
+			routes = append(routes, methodRoute{method, route})
+			return nil
+		})
+	}
+	r.mu.RUnlock()
+
+	for _, mr := range routes {
+		if err := fn(mr.method, mr.route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+@info Lists every registered route sorted by template then method, the order is stable across runs
+@param {...string} [tags] Only lists the routes carrying all of these tags
+@returns {[]RouteInfo}
+*/
+func (r *Router) Routes(tags ...string) []RouteInfo {
+	return r.routeInfos(func(route *Route) bool { return route.hasTags(tags) })
+}
+
+/**
+@info Lists the routes that never served a request since TrackHits was turned on, sorted like Routes
+@returns {[]RouteInfo}
+*/
+func (r *Router) UnusedRoutes() []RouteInfo {
+	return r.routeInfos(func(route *Route) bool {
+		return atomic.LoadUint64(&route.hits) == 0
+	})
+}
+
+/**
+@info Lists the routes passing a filter sorted by template then method
+@param {func(*Route) bool} [keep] The filter
+@returns {[]RouteInfo}
+*/
+func (r *Router) routeInfos(keep func(*Route) bool) []RouteInfo {
+	var infos []RouteInfo
+	r.walk(func(method string, route *Route) error {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		if keep(route) {
+			infos = append(infos, route.info(method))
+		}
+		return nil
+	})
+	sort.SliceStable(infos, func(i, j int) bool {
+		if infos[i].Template != infos[j].Template {
+			return infos[i].Template < infos[j].Template
+		}
+		return infos[i].Method < infos[j].Method
+	})
+	return infos
 }
 
 /**
@@ -152,43 +515,245 @@ func (r *Router) GetRouterRoutes() map[string]*Routes {
 }
 
 /**
-@info Appends all routes to core router instance
+@info Appends all routes to core router instance. The routes keep their modifiers, named stacks and
+handlers, while everything configured on the router itself, like the base path, middleware, fallback
+and matching options, is the core router's. A warning is logged for every such setting of the
+appended router that differs and won't apply
 @param {Router} [Router] The router instance to append
 @returns {Router}
 */
 func (r *Router) UseRouter(Router *Router) *Router {
-	for t, v := range Router.GetRouterRoutes() {
-		for i, vl := range v.roots {
-			for _, handle := range vl {
-				r.Register(t, i, handle.function)
-			}
-		}
-	}
+	r.copyRoutes("", Router, nil)
 	return r
 }
 
 /**
-@info Mounts router to a specific path
-@param {string} [path] The route path
+@info Mounts router to a specific path, its router level settings don't apply like with UseRouter
+@param {string} [path] The route path, its params are readable by the mounted handlers like their own
 @param {*Router} [router] Minima router instance
+@param {...func(http.Handler)http.Handler} [mw] The middleware wrapping only the mounted routes
+@returns {*Router}
+*/
+func (r *Router) Mount(path string, Router *Router, mw ...func(http.Handler) http.Handler) *Router {
+	r.copyRoutes(strings.TrimSuffix(path, "/"), Router, mw)
+	return r
+}
+
+/**
+@info Mounts a net/http handler under a prefix for every method, the handler sees the request path
+with the prefix stripped, like with http.StripPrefix
+@param {string} [prefix] The path prefix, it can hold params
+@param {http.Handler} [h] The handler to mount
 @returns {*Router}
 */
-func (r *Router) Mount(path string, Router *Router) *Router {
-	for t, v := range Router.GetRouterRoutes() {
-		for i, vl := range v.roots {
-			for _, handle := range vl {
-				r.Register(t, path+i, handle.function)
+func (r *Router) Handle(prefix string, h http.Handler) *Router {
+	return r.handlePrefix(methods, prefix, h)
+}
+
+/**
+@info Redirects the GET and HEAD requests of a path to another, filling the params of the target
+template with the ones captured from the request, like /old-blog/:slug to /blog/:slug. The query is kept
+@param {string} [from] The route path to redirect
+@param {string} [to] The target path template, its params have to exist in from
+@param {int} [code] The redirect status, 0 uses 301
+@returns {*RouteBuilder}
+*/
+func (r *Router) Alias(from string, to string, code int) *RouteBuilder {
+	if code == 0 {
+		code = http.StatusMovedPermanently
+	}
+	if code < 300 || code >= 400 {
+		panic(fmt.Sprintf("Minima: Alias %s needs a 3xx status, got %d", from, code))
+	}
+	source, err := NewRoutes().Add(from, nil)
+	if err != nil {
+		panic("Minima: " + err.Error())
+	}
+	captured := make(map[string]bool)
+	for _, p := range source.partNames {
+		captured[p.name] = !p.fixed
+	}
+	target := strings.Split(to, "/")
+	for _, part := range target {
+		if name, ok := aliasParam(part); ok && !captured[name] {
+			panic(fmt.Sprintf("Minima: Alias target %s uses param %s that %s doesn't capture", to, name, from))
+		}
+	}
+
+	return r.Get(from, func(w http.ResponseWriter, req *http.Request) {
+		params := Params(req)
+		parts := make([]string, len(target))
+		for i, part := range target {
+			name, ok := aliasParam(part)
+			if !ok {
+				parts[i] = part
+				continue
 			}
+			segments := strings.Split(params[name], "/")
+			for j, segment := range segments {
+				segments[j] = url.PathEscape(segment)
+			}
+			parts[i] = strings.Join(segments, "/")
 		}
+		r.mu.RLock()
+		base := r.basePath
+		r.mu.RUnlock()
+		u := url.URL{RawPath: base + strings.Join(parts, "/"), RawQuery: req.URL.RawQuery}
+		u.Path, _ = url.PathUnescape(u.RawPath)
+		http.Redirect(w, req, u.String(), code)
+	})
+}
+
+/**
+@info Gets the param name of a path template segment
+@param {string} [part] The segment, like :id, :id|int or *rest
+@returns {string, bool} false when the segment is static
+*/
+func aliasParam(part string) (string, bool) {
+	if !strings.HasPrefix(part, ":") && !strings.HasPrefix(part, "*") {
+		return "", false
+	}
+	name := part[1:]
+	if i := strings.Index(name, "|"); i >= 0 {
+		name = name[:i]
+	}
+	return name, true
+}
+
+/**
+@info Mounts a net/http handler under a prefix for some methods, with the prefix stripped from the request path
+@param {[]string} [methods] The methods to register
+@param {string} [prefix] The path prefix, it can hold params
+@param {http.Handler} [h] The handler to mount
+@returns {*Router}
+*/
+func (r *Router) handlePrefix(methods []string, prefix string, h http.Handler) *Router {
+	prefix = strings.TrimSuffix(prefix, "/")
+	depth := strings.Count(prefix, "/")
+	version := strings.TrimPrefix(prefix, "/")
+	if i := strings.IndexByte(version, '/'); i >= 0 {
+		version = version[:i]
+	}
+	strip := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path, _ := r.stripBasePath(req.URL.Path)
+		n := depth
+		// A request reaching a versioned mount through the default version
+		// doesn't carry the version segment, so there's one less to strip
+		r.mu.RLock()
+		if _, ok := r.versionedPath(path); ok && version == r.defaultVersion {
+			n--
+		}
+		r.mu.RUnlock()
+		u := *req.URL
+		u.Path, u.RawPath = trimSegments(path, n), ""
+		sub := new(http.Request)
+		*sub = *req
+		sub.URL = &u
+		h.ServeHTTP(w, sub)
+	})
+	for _, method := range methods {
+		r.mustRegister(method, prefix+"/*path", strip)
 	}
 	return r
 }
 
 /**
- * @info Injects Minima middleware to the stack
- * @param {...Handler} [handler] The handler stack to append
- * @returns {}
+@info Removes leading segments from a path
+@param {string} [path] The path
+@param {int} [n] The number of segments to remove
+@returns {string} The rest of the path, / when nothing is left
+*/
+func trimSegments(path string, n int) string {
+	for i := 0; i < n && path != ""; i++ {
+		if next := strings.IndexByte(path[1:], '/'); next >= 0 {
+			path = path[next+1:]
+		} else {
+			path = ""
+		}
+	}
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+/**
+@info Lists the router level settings of another router that differ from this one's, they're lost
+when its routes are copied since the copies are served with this router's settings
+@param {*Router} [src] The router the routes are copied from
+@returns {[]string}
+*/
+func (r *Router) configConflicts(src *Router) []string {
+	r.mu.RLock()
+	basePath, defaultVersion := r.basePath, r.defaultVersion
+	r.mu.RUnlock()
+	src.mu.RLock()
+	defer src.mu.RUnlock()
+	var conflicts []string
+	differs := func(name string, theirs, ours interface{}) {
+		if theirs != ours {
+			conflicts = append(conflicts, fmt.Sprintf("%s is %v instead of %v", name, theirs, ours))
+		}
+	}
+	differs("base path", src.basePath, basePath)
+	differs("StrictMethods", src.StrictMethods, r.StrictMethods)
+	differs("UseEncodedPath", src.UseEncodedPath, r.UseEncodedPath)
+	differs("RedirectTrailingSlash", src.RedirectTrailingSlash, r.RedirectTrailingSlash)
+	differs("RedirectCleanPath", src.RedirectCleanPath, r.RedirectCleanPath)
+	differs("default version", src.defaultVersion, defaultVersion)
+	if len(src.middlewares) > 0 {
+		conflicts = append(conflicts, "its UseRaw middleware isn't carried, pass it to Mount instead")
+	}
+	if len(src.methodMiddlewares) > 0 {
+		conflicts = append(conflicts, "its UseFor middleware isn't carried")
+	}
+	if src.fallback != nil {
+		conflicts = append(conflicts, "its fallback handler isn't carried")
+	}
+	return conflicts
+}
 
+/**
+@info Registers copies of every route of another router, keeping their modifiers
+@param {string} [prefix] The path prefix to register the routes under
+@param {*Router} [src] The router to copy the routes from
+@param {[]func(http.Handler)http.Handler} [mw] The middleware wrapping the copied handlers
+*/
+func (r *Router) copyRoutes(prefix string, src *Router, mw []func(http.Handler) http.Handler) {
+	r.mu.RLock()
+	frozen := r.frozen
+	r.mu.RUnlock()
+	if frozen {
+		panic(fmt.Sprintf("Minima: %s: can't mount routes under %q", ErrRouterFrozen, prefix))
+	}
+	for _, conflict := range r.configConflicts(src) {
+		log.Printf("Minima: Merged router config won't apply, %s", conflict)
+	}
+	// Bring along the stacks the copied routes use, the ones defined here win
+	src.mu.RLock()
+	stacks := src.stacks
+	src.mu.RUnlock()
+	for name, stack := range stacks {
+		r.mu.RLock()
+		_, ok := r.stacks[name]
+		r.mu.RUnlock()
+		if !ok {
+			r.DefineStack(name, stack...)
+		}
+	}
+	src.walk(func(method string, route *Route) error {
+		copied, err := r.register(method, prefix+route.template(), chain(mw, route.function))
+		if err != nil {
+			log.Printf("Minima: Skipping route %s %s: %s", method, prefix+route.template(), err)
+			return nil
+		}
+		r.mu.Lock()
+		copied.inherit(route)
+		r.invalidateChains()
+		r.mu.Unlock()
+		return nil
+	})
+}
 
 /**
  * @info Injects net/http middleware to the stack
@@ -196,51 +761,946 @@ func (r *Router) Mount(path string, Router *Router) *Router {
  * @returns {}
  */
 func (r *Router) UseRaw(handler ...func(http.Handler) http.Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("UseRaw")
 	if r.handler != nil {
 		panic("Minima: Middlewares can't go after the routes are mounted")
 	}
 	r.middlewares = append(r.middlewares, handler...)
 }
 
-//A dummy function that runs at the end of the middleware stack
-func (r *Router) middlewareHTTP(w http.ResponseWriter, rq *http.Request) {}
+/**
+ * @info Injects net/http middleware wrapping the handlers of one method's routes, "*" applies to every method.
+ * The "*" middleware is outermost, followed by the method specific ones, each in registration order
+ * @param {string} [method] The method the middleware applies to, or "*"
+ * @param {...func(http.Handler)http.Handler} [handler] The handler stack to append
+ * @returns {}
+ */
+func (r *Router) UseFor(method string, handler ...func(http.Handler) http.Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.routes[method]; !ok && method != "*" {
+		panic("Minima: " + fmt.Errorf("%w %s", ErrInvalidMethod, method).Error())
+	}
+	r.mustNotBeFrozen("UseFor")
+	if r.methodMiddlewares == nil {
+		r.methodMiddlewares = make(map[string][]func(http.Handler) http.Handler)
+	}
+	r.methodMiddlewares[method] = append(r.methodMiddlewares[method], handler...)
+	r.invalidateChains()
+}
+
+/**
+ * @info Tees the response bodies of the given routes to the AuditSink, up to MaxAuditBytes each
+ * @param {...string} [routes] The route templates, like /payments/:id, or route names
+ * @returns {}
+ */
+func (r *Router) AuditResponses(routes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("AuditResponses")
+	if r.audited == nil {
+		r.audited = make(map[string]bool)
+	}
+	for _, route := range routes {
+		r.audited[route] = true
+	}
+	r.invalidateChains()
+}
+
+/**
+ * @info Wraps a matched route handler so its response body is passed to the AuditSink once served
+ * @param {RouteInfo} [info] The matched route
+ * @param {http.Handler} [h] The route handler
+ * @returns {http.Handler}
+ */
+func (r *Router) auditHandler(info RouteInfo, h http.Handler) http.Handler {
+	limit, sink := r.MaxAuditBytes, r.AuditSink
+	if limit <= 0 {
+		limit = 64 << 10
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		aw := &auditWriter{ResponseWriter: w, limit: limit}
+		h.ServeHTTP(aw, req)
+		sink(info, aw.body.Bytes())
+	})
+}
+
+/**
+ * @info Defines a named middleware stack routes can opt into with UseStack, redefining a name replaces it
+ * @param {string} [name] The stack name
+ * @param {...func(http.Handler)http.Handler} [handler] The handler stack
+ * @returns {}
+ */
+func (r *Router) DefineStack(name string, handler ...func(http.Handler) http.Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("DefineStack")
+	if r.stacks == nil {
+		r.stacks = make(map[string][]func(http.Handler) http.Handler)
+	}
+	r.stacks[name] = append([]func(http.Handler) http.Handler(nil), handler...)
+	r.invalidateChains()
+}
+
+// The composed handler of a route, cached until the middleware it's made of changes
+type routeChain struct {
+	handler http.Handler
+	err     error
+	audit   bool
+}
+
+/**
+ * @info Gets the handler of a matched route wrapped with its method, stack and route middleware,
+ * composing it on the first request and caching it until invalidateChains. The caller holds the read lock
+ * @param {*Route} [route] The matched route
+ * @param {string} [method] The method the route is registered under
+ * @returns {http.Handler, error} The error names an undefined middleware stack
+ */
+func (r *Router) routeHandler(route *Route, method string) (http.Handler, error) {
+	// AuditSink is a plain field, so whether it applies is checked on every request
+	audit := r.AuditSink != nil && (r.audited[route.template()] || route.name != "" && r.audited[route.name])
+	r.chainsMu.RLock()
+	c, ok := r.chains[route]
+	r.chainsMu.RUnlock()
+	if ok && c.audit == audit {
+		return c.handler, c.err
+	}
+
+	c = &routeChain{audit: audit}
+	if h, err := r.stackHandler(route, route.handler()); err != nil {
+		c.err = err
+	} else {
+		h = r.methodHandler(method, h)
+		if audit {
+			h = r.auditHandler(route.info(method), h)
+		}
+		c.handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			r.mu.RLock()
+			route.applyHeaders(w)
+			if route.cors != nil {
+				route.cors.apply(w, req)
+			}
+			r.mu.RUnlock()
+			h.ServeHTTP(w, req)
+		})
+	}
+	r.chainsMu.Lock()
+	if r.chains == nil {
+		r.chains = make(map[*Route]*routeChain)
+	}
+	r.chains[route] = c
+	r.chainsMu.Unlock()
+	return c.handler, c.err
+}
+
+/**
+@info Marks the setup as done. Registering, mounting or modifying routes and adding middleware afterwards
+panics, or fails with ErrRouterFrozen where the method returns an error. The middleware chain of every route
+is composed right away rather than on its first request. Routes are already kept in matching order as they
+are registered, so the table itself is left as is. Reset lifts it
+@returns {*Router}
+*/
+func (r *Router) Freeze() *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.handler == nil {
+		r.buildHandler()
+	}
+	r.frozen = true
+	for method, routes := range r.routes {
+		routes.walk(func(route *Route) error {
+			r.routeHandler(route, method)
+			return nil
+		})
+	}
+	return r
+}
+
+/**
+@info Panics when the router is frozen, the caller holds the write lock
+@param {string} [action] The method called, for the message
+*/
+func (r *Router) mustNotBeFrozen(action string) {
+	if r.frozen {
+		panic(fmt.Sprintf("Minima: %s: can't call %s after Freeze", ErrRouterFrozen, action))
+	}
+}
+
+/**
+ * @info Drops the cached route handlers, for anything changing the middleware they're made of.
+ * The caller holds the write lock so no request is reading them
+ */
+func (r *Router) invalidateChains() {
+	r.chains = nil
+}
+
+/**
+ * @info Wraps a matched route handler with the named stacks it uses, the first name is outermost
+ * @param {*Route} [route] The matched route
+ * @param {http.Handler} [h] The route handler
+ * @returns {http.Handler, error}
+ */
+func (r *Router) stackHandler(route *Route, h http.Handler) (http.Handler, error) {
+	for i := len(route.stacks) - 1; i >= 0; i-- {
+		stack, ok := r.stacks[route.stacks[i]]
+		if !ok {
+			return nil, fmt.Errorf("undefined middleware stack %q", route.stacks[i])
+		}
+		h = chain(stack, h)
+	}
+	return h, nil
+}
+
+/**
+ * @info Wraps a matched route handler with the middleware registered for its method
+ * @param {string} [method] The request method
+ * @param {http.Handler} [h] The route handler
+ * @returns {http.Handler}
+ */
+func (r *Router) methodHandler(method string, h http.Handler) http.Handler {
+	all, own := r.methodMiddlewares["*"], r.methodMiddlewares[method]
+	if len(all) == 0 && len(own) == 0 {
+		return h
+	}
+	stack := make([]func(http.Handler) http.Handler, 0, len(all)+len(own))
+	stack = append(stack, all...)
+	stack = append(stack, own...)
+	return chain(stack, h)
+}
+
+// The end of the middleware stack, handing the request the middleware passed on to the handler it wraps
+func (r *Router) middlewareHTTP(w http.ResponseWriter, rq *http.Request) {
+	if rc := getRouteContext(rq); rc != nil && rc.next != nil {
+		next := rc.next
+		rc.next = nil
+		next.ServeHTTP(w, rq)
+	}
+}
 
 /**
  * @info Builds whole middleware stack chain into single handler
  */
 func (r *Router) buildHandler() {
 	r.handler = chain(r.middlewares, http.HandlerFunc(r.middlewareHTTP))
+	if len(r.middlewares) > 0 {
+		atomic.StoreInt32(&r.hasMiddleware, 1)
+	}
+}
+
+/**
+ * @info Serves a handler wrapped by the middleware stack, so it gets the request the middleware
+ * passes on, context included. The stack is skipped entirely when there is no middleware
+ * @param {http.ResponseWriter} [w] The net/http response instance
+ * @param {http.Request} [req] The net/http request instance
+ * @param {*routeContext} [rc] The request router state
+ * @param {http.Handler} [next] The handler the middleware wraps
+ */
+func (r *Router) runMiddlewares(w http.ResponseWriter, req *http.Request, rc *routeContext, next http.Handler) {
+	// The middleware can't change once the chain is built, so the flag set
+	// with it spares the requests of routers without any the lock
+	if atomic.LoadInt32(&r.hasMiddleware) == 0 {
+		next.ServeHTTP(w, req)
+		return
+	}
+	r.mu.RLock()
+	h := r.handler
+	r.mu.RUnlock()
+	if h == nil {
+		// Reset cleared the chain since the flag was read
+		next.ServeHTTP(w, req)
+		return
+	}
+	rc.next = next
+	h.ServeHTTP(w, req)
 }
 
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	f, pram, match := r.routes[req.Method].Get(req.URL.Path)
-	prm := Param{
-		path: req.URL.Path,
-		param: pram,
+	r.mu.RLock()
+	after := r.afterHooks
+	r.mu.RUnlock()
+	if len(after) == 0 {
+		r.serve(w, req)
+		return
+	}
+	// The after hooks see every response, the errors the router answers
+	// itself before or after matching included, and the route it matched
+	req, _ = withRouterContext(req, r)
+	rw := newResponseWriter(w, http.StatusOK)
+	r.serve(rw, req)
+	status := rw.status
+	if status == 0 {
+		status = rw.defaultStatus
+	}
+	for _, fn := range after {
+		fn(req, status)
+	}
+}
+
+/**
+@info Serves a request, everything ServeHTTP does apart from the after hooks
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {*http.Request} [req] The net/http request instance
+*/
+func (r *Router) serve(w http.ResponseWriter, req *http.Request) {
+	// OPTIONS * asks about the server as a whole rather than a resource
+	if req.Method == http.MethodOptions && req.URL.Path == "*" {
+		r.mu.RLock()
+		w.Header().Set("Allow", strings.Join(r.serverMethods(), ", "))
+		r.mu.RUnlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// Some proxies forward the path without its leading slash, every
+	// stored prefix starts with one so match it as if it was there
+	if !strings.HasPrefix(req.URL.Path, "/") {
+		req = withLeadingSlash(req)
+	}
+	if r.DisableMIMESniffing {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+	}
+	r.mu.RLock()
+	for key, values := range r.headers {
+		w.Header()[key] = append([]string(nil), values...)
+	}
+	if len(r.forcedHeaders) > 0 {
+		w = &forcedHeaderWriter{ResponseWriter: w, headers: r.forcedHeaders}
+	}
+	base := r.base
+	r.mu.RUnlock()
+	if base != nil {
+		var cancel context.CancelFunc
+		req, cancel = withBaseContext(req, base)
+		defer cancel()
+	}
+	if r.MaxHeaderBytes > 0 && headerSize(req) > r.MaxHeaderBytes {
+		r.writeError(w, http.StatusRequestHeaderFieldsTooLarge, "Request headers too large")
+		return
+	}
+	if r.MaxBodyBytes > 0 && req.Body != nil && req.Body != http.NoBody {
+		if req.ContentLength > int64(r.MaxBodyBytes) {
+			r.writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		req.Body = http.MaxBytesReader(w, req.Body, int64(r.MaxBodyBytes))
+	}
+	if r.MaxURLLength > 0 && len(requestURI(req)) > r.MaxURLLength {
+		r.writeError(w, http.StatusRequestURITooLong, "Request URL too long")
+		return
+	}
+	// Reject absurdly deep paths before walking the routes table, every
+	// segment costs another prefix lookup
+	if r.MaxPathSegments > 0 && strings.Count(req.URL.Path, "/") > r.MaxPathSegments {
+		r.writeError(w, http.StatusRequestURITooLong, "Request path too long")
+		return
+	}
+	if target := r.canonicalPath(req.URL.Path); target != req.URL.Path {
+		u := *req.URL
+		u.Path, u.RawPath = target, ""
+		http.Redirect(w, req, u.String(), r.redirectCode(req.Method))
+		return
+	}
+	reqPath := req.URL.Path
+	if r.UseEncodedPath {
+		var err error
+		if reqPath, err = encodedPath(req.URL); err != nil {
+			r.writeError(w, http.StatusBadRequest, "Malformed path escape")
+			return
+		}
+	}
+	if r.NormalizeUnicode {
+		reqPath = norm.NFC.String(reqPath)
+	}
+	path, ok := r.stripBasePath(reqPath)
+	if !ok {
+		r.serveUnmatched(w, req)
+		return
+	}
+	// The router state is only attached to the requests reaching the route
+	// lookup, the ones answered above don't pay for it
+	req, rc := withRouterContext(req, r)
+	var route *Route
+	var pram map[string]string
+	var match bool
+	var h http.Handler
+	var err error
+	var allowed []string
+	var notAllowed func(http.ResponseWriter, *http.Request, []string)
+	var query map[string][]string
+	var missing *ValidationError
+	var health bool
+	var preflight *CORSOptions
+	var preflightMethod string
+	var before []func(*http.Request)
+	method := r.requestMethod(req)
+
+	// Resolve everything the request needs under the read lock, the
+	// handlers run without it so they can't hold up registration
+	r.mu.RLock()
+	start := time.Now()
+	if routes, ok := r.routes[method]; ok {
+		route, pram, match = r.find(routes, path, req)
+	}
+	if !match && method == http.MethodHead {
+		// Answer HEAD with the GET route, its method middleware included, discarding the body
+		if route, pram, match = r.find(r.routes[http.MethodGet], path, req); match {
+			method = http.MethodGet
+			w = &headWriter{w}
+		}
+	}
+	rc.matchDuration = time.Since(start)
+	before = r.beforeHooks
+	if match {
+		health = route.healthCheck
+		query, missing = route.queryParams(req)
+		h, err = r.routeHandler(route, method)
+	} else {
+		if allowed = r.allowedMethods(req, path); len(allowed) == 0 {
+			if versioned, ok := r.versionedPath(path); ok {
+				allowed = r.allowedMethods(req, versioned)
+			}
+		}
+		notAllowed = r.methodNotAllowed
+		if requested := req.Header.Get("Access-Control-Request-Method"); method == http.MethodOptions && requested != "" {
+			preflight, preflightMethod = r.preflightPolicy(strings.ToUpper(requested), path, req)
+		}
+	}
+	r.mu.RUnlock()
+
+	if atomic.LoadInt32(&r.draining) == 1 && !health {
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Retry-After", strconv.Itoa(drainRetryAfter))
+		r.writeError(w, http.StatusServiceUnavailable, "Server is shutting down")
+		return
+	}
+	if match && r.TrackHits {
+		atomic.AddUint64(&route.hits, 1)
+	}
+	if match && r.UseEncodedPath {
+		for name, value := range pram {
+			unescaped, err := url.PathUnescape(value)
+			if err != nil {
+				r.writeError(w, http.StatusBadRequest, "Malformed path escape")
+				return
+			}
+			pram[name] = unescaped
+		}
+	}
+	rc.matched = match
+	rc.route = route
+	rc.params = pram
+	rc.query = query
+	rc.format = ""
+	if match {
+		rc.format, _ = route.format(path)
+	}
+	for _, fn := range before {
+		fn(req)
 	}
-	r.params = append(r.params, prm)
 	if match {
 		if err := req.ParseForm(); err != nil {
 			log.Printf("Error parsing form: %s", err)
+			r.writeError(w, http.StatusBadRequest, "Malformed request form")
+			return
+		}
+		if missing != nil {
+			WriteValidationError(w, req, missing)
+			return
+		}
+		if err != nil {
+			log.Printf("Minima: %s", err)
+			r.writeError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		r.runMiddlewares(w, req, rc, h)
+
+	} else if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		if method == http.MethodOptions {
+			if preflight != nil {
+				preflight.preflight(w, req, preflightMethod)
+			}
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		if r.handler != nil {
-			r.handler.ServeHTTP(w, req)
+		if notAllowed != nil {
+			rw := newResponseWriter(w, http.StatusMethodNotAllowed)
+			notAllowed(rw, req, allowed)
+			rw.finish()
+			return
 		}
-		f.ServeHTTP(w,req)
-		
+		r.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
 	} else {
-		
-		w.Write([]byte("No matching route found"))
-		
+		r.serveUnmatched(w, req)
 	}
 }
 
-func (r *Router) GetParam(req *http.Request, key string) string {
-	for _, p := range r.params {
-          if p.path == req.URL.Path {
-		return p.param[key]
-	  }
+/**
+@info Adds a callback run once the route of a request is looked up, before its handler or the fallback,
+404, 405 or validation error answers it. The requests rejected before the lookup, like 414, 431, 413, the
+draining 503 and the canonical path redirects, skip it. Lighter than middleware for observing requests,
+the callbacks run in registration order
+@param {func(*http.Request)} [fn] The callback
+@returns {*Router}
+*/
+func (r *Router) OnBeforeHandler(fn func(r *http.Request)) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("OnBeforeHandler")
+	r.beforeHooks = append(r.beforeHooks, fn)
+	return r
+}
+
+/**
+@info Adds a callback run with the response status after every request the router answers, the ones it
+rejects itself before or after the route lookup included. The callbacks run in registration order, and not
+when the handler panics
+@param {func(*http.Request, int)} [fn] The callback
+@returns {*Router}
+*/
+func (r *Router) OnAfterHandler(fn func(r *http.Request, status int)) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("OnAfterHandler")
+	r.afterHooks = append(r.afterHooks, fn)
+	return r
+}
+
+/**
+@info Adds a callback run after the handler of the requests answered with a 4xx or 5xx status, for error
+counters and alerts, the router's own 400, 404, 405, 413, 414, 431, 500 and 503 responses included. It's an
+OnAfterHandler hook, so it runs in the same order
+@param {func(*http.Request, int)} [fn] The callback
+@returns {*Router}
+*/
+func (r *Router) OnError(fn func(r *http.Request, status int)) *Router {
+	return r.OnAfterHandler(func(req *http.Request, status int) {
+		if status >= http.StatusBadRequest {
+			fn(req, status)
+		}
+	})
+}
+
+// Escapes what has to stay escaped inside a decoded path segment
+var segmentEscaper = strings.NewReplacer("%", "%25", "/", "%2F")
+
+/**
+@info Gets the request path with every segment decoded except for the / and % inside it, so an encoded /
+stays inside its param while the static parts compare with the unescaped ones the routes are registered with.
+The raw path as sent is used when there is one so malformed escapes aren't masked by re-escaping the decoded path
+@param {*url.URL} [u] The request URL
+@returns {string, error} The error of a malformed escape
+*/
+func encodedPath(u *url.URL) (string, error) {
+	raw := u.RawPath
+	if raw == "" {
+		raw = u.EscapedPath()
+	}
+	segments := strings.Split(raw, "/")
+	for i, segment := range segments {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			return "", err
+		}
+		segments[i] = segmentEscaper.Replace(decoded)
 	}
-	return ""
-}
\ No newline at end of file
+	return strings.Join(segments, "/"), nil
+}
+
+/**
+@info Copies a request with a leading slash added to its path, leaving the original untouched
+@param {*http.Request} [req] The net/http request instance
+@returns {*http.Request}
+*/
+func withLeadingSlash(req *http.Request) *http.Request {
+	u := *req.URL
+	u.Path = "/" + u.Path
+	if u.RawPath != "" {
+		u.RawPath = "/" + u.RawPath
+	}
+	r := *req
+	r.URL = &u
+	return &r
+}
+
+/**
+@info Gets the canonical form of a request path for the redirect options
+@param {string} [p] The request path
+@returns {string} The path itself when it's already canonical or no redirect is enabled
+*/
+func (r *Router) canonicalPath(p string) string {
+	if r.RedirectCleanPath && p != "" {
+		clean := path.Clean(p)
+		if strings.HasSuffix(p, "/") && clean != "/" {
+			clean += "/"
+		}
+		p = clean
+	}
+	if r.RedirectTrailingSlash && len(p) > 1 && strings.HasSuffix(p, "/") {
+		if p = strings.TrimRight(p, "/"); p == "" {
+			p = "/"
+		}
+	}
+	return p
+}
+
+/**
+@info Gets the status of the canonicalization redirects, a RedirectCode that isn't 3xx falls back to the default
+@param {string} [method] The request method
+@returns {int}
+*/
+func (r *Router) redirectCode(method string) int {
+	if r.RedirectCode >= 300 && r.RedirectCode < 400 {
+		return r.RedirectCode
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
+}
+
+/**
+@info Gets the method to route the request with, upper cased and defaulting to GET unless StrictMethods is set
+@param {*http.Request} [req] The net/http request instance
+@returns {string}
+*/
+func (r *Router) requestMethod(req *http.Request) string {
+	if r.StrictMethods {
+		return req.Method
+	}
+	if req.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(req.Method)
+}
+
+/**
+@info Serves a request no route matched, delegating to the fallback handler when one is set
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {http.Request} [req] The net/http request instance
+*/
+func (r *Router) serveUnmatched(w http.ResponseWriter, req *http.Request) {
+	r.mu.RLock()
+	fallback := r.fallback
+	r.mu.RUnlock()
+	if fallback == nil {
+		r.notFound(w)
+		return
+	}
+	req, rc := withRouteContext(req)
+	r.runMiddlewares(w, req, rc, fallback)
+}
+
+/**
+@info Sets a header on every response, 404s and other built-in responses included. Handlers and
+middleware setting the same header replace it
+@param {string} [key] The header name
+@param {string} [value] The header value
+@returns {*Router}
+*/
+func (r *Router) SetHeader(key, value string) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.headers == nil {
+		r.headers = make(http.Header)
+	}
+	r.headers.Set(key, value)
+	return r
+}
+
+/**
+@info Sets a header on every response like SetHeader, replacing the value handlers and middleware set
+@param {string} [key] The header name
+@param {string} [value] The header value
+@returns {*Router}
+*/
+func (r *Router) ForceHeader(key, value string) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.forcedHeaders == nil {
+		r.forcedHeaders = make(http.Header)
+	}
+	r.forcedHeaders.Set(key, value)
+	return r
+}
+
+/**
+@info Sets the function writing the 405 responses instead of the built-in one, the router sets the Allow
+header before calling it and the status defaults to 405 unless it writes another one
+@param {func(http.ResponseWriter, *http.Request, []string)} [fn] The function, getting the allowed methods
+@returns {*Router}
+*/
+func (r *Router) MethodNotAllowed(fn func(w http.ResponseWriter, req *http.Request, allowed []string)) *Router {
+	r.mu.Lock()
+	r.methodNotAllowed = fn
+	r.mu.Unlock()
+	return r
+}
+
+/**
+@info Sets the handler requests are delegated to when no route matches, instead of answering 404.
+The middleware stack wraps it and it gets the original request, base path included
+@param {http.Handler} [next] The fallback handler, like another router
+@returns {*Router}
+*/
+func (r *Router) Fallback(next http.Handler) *Router {
+	r.mu.Lock()
+	r.fallback = next
+	r.mu.Unlock()
+	return r
+}
+
+/**
+@info Returns the raw request target, falling back to the parsed URL for requests built in code
+@param {*http.Request} [req] The net/http request instance
+@returns {string}
+*/
+func requestURI(req *http.Request) string {
+	if req.RequestURI != "" {
+		return req.RequestURI
+	}
+	return req.URL.RequestURI()
+}
+
+/**
+@info Approximates the size of the request headers as sent, each line being name: value and CRLF
+@param {*http.Request} [req] The net/http request instance
+@returns {int}
+*/
+func headerSize(req *http.Request) int {
+	size := len(req.Host) + len("Host: \r\n")
+	for name, values := range req.Header {
+		for _, value := range values {
+			size += len(name) + len(value) + len(": \r\n")
+		}
+	}
+	return size
+}
+
+/**
+@info Sets the message of the built-in 404 response
+@param {string} [message] The not found message
+@returns {*Router}
+*/
+func (r *Router) SetNotFoundMessage(message string) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notFoundMessage = message
+	return r
+}
+
+/**
+@info Sets the version serving requests without a version prefix, so /users is answered like /v1/users
+when no unversioned route matches. Only the first segment is checked and it counts as a version when
+it's a v followed by digits, like v2. Registered templates keep their prefix, so Routes lists /v1/users
+and URLs built from the templates point at the versioned form
+@param {string} [version] The default version, like v1
+@returns {*Router}
+*/
+func (r *Router) DefaultVersion(version string) *Router {
+	r.mu.Lock()
+	r.defaultVersion = strings.Trim(version, "/")
+	r.mu.Unlock()
+	return r
+}
+
+/**
+@info Finds the route for a path in a method table, trying the default version when the path has none
+@param {*Routes} [routes] The method table
+@param {string} [path] The request path, base path stripped
+@param {*http.Request} [req] The request for the route match functions
+@returns {*Route, map[string]string, bool}
+*/
+func (r *Router) find(routes *Routes, path string, req *http.Request) (*Route, map[string]string, bool) {
+	if route, params, ok := routes.find(path, req); ok {
+		return route, params, true
+	}
+	if versioned, ok := r.versionedPath(path); ok {
+		return routes.find(versioned, req)
+	}
+	return nil, nil, false
+}
+
+/**
+@info Gets the template of the route a request would hit, without serving it. Routes with match
+functions or host restrictions are checked against a bare request for the path
+@param {string} [method] The request method
+@param {string} [path] The concrete request path, like /users/42
+@returns {string, bool} The template, like /users/:id, false when no route matches
+*/
+func (r *Router) MatchTemplate(method string, path string) (string, bool) {
+	if r.NormalizeUnicode {
+		path = norm.NFC.String(path)
+	}
+	path, ok := r.stripBasePath(path)
+	if !ok {
+		return "", false
+	}
+	req := &http.Request{Method: method, URL: &url.URL{Path: path}, Header: make(http.Header)}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var route *Route
+	var match bool
+	if routes, ok := r.routes[method]; ok {
+		route, _, match = r.find(routes, path, req)
+	}
+	if !match && method == http.MethodHead {
+		route, _, match = r.find(r.routes[http.MethodGet], path, req)
+	}
+	if !match {
+		return "", false
+	}
+	return route.template(), true
+}
+
+/**
+@info Prefixes a path with the default version
+@param {string} [path] The request path, base path stripped
+@returns {string, bool} false when there's no default version or the path already has a version
+*/
+func (r *Router) versionedPath(path string) (string, bool) {
+	if r.defaultVersion == "" {
+		return "", false
+	}
+	first := strings.TrimPrefix(path, "/")
+	if i := strings.IndexByte(first, '/'); i >= 0 {
+		first = first[:i]
+	}
+	if isVersion(first) {
+		return "", false
+	}
+	return "/" + r.defaultVersion + path, true
+}
+
+/**
+@info Reports whether a path segment is a version, a v followed by digits
+@param {string} [segment] The path segment
+@returns {bool}
+*/
+func isVersion(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' {
+		return false
+	}
+	for _, c := range segment[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+@info Sets the base path the router is served under, it's stripped from requests before matching
+@param {string} [path] The base path, like /myapp
+@returns {*Router}
+*/
+func (r *Router) SetBasePath(path string) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.basePath = strings.TrimSuffix(path, "/")
+	return r
+}
+
+/**
+@info Strips the base path from the request path
+@param {string} [path] The request path
+@returns {string, bool} The path to match and whether it was under the base path
+*/
+func (r *Router) stripBasePath(path string) (string, bool) {
+	r.mu.RLock()
+	base := r.basePath
+	r.mu.RUnlock()
+	if base == "" {
+		return path, true
+	}
+	if path == base {
+		return "/", true
+	}
+	if strings.HasPrefix(path, base+"/") {
+		return path[len(base):], true
+	}
+	return "", false
+}
+
+/**
+@info Probes every method table for routes matching the path
+@param {*http.Request} [req] The request for the route match functions
+@param {string} [path] The request path
+@returns {[]string}
+*/
+func (r *Router) allowedMethods(req *http.Request, path string) []string {
+	var allowed []string
+	var options, get, head bool
+	for method, routes := range r.routes {
+		if _, _, ok := routes.find(path, req); ok {
+			allowed = append(allowed, method)
+			options = options || method == http.MethodOptions
+			get = get || method == http.MethodGet
+			head = head || method == http.MethodHead
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	if !options {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	if get && !head {
+		allowed = append(allowed, http.MethodHead)
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+/**
+@info Finds the CORS policy of the route a preflight request asks about. The caller holds the read lock
+@param {string} [method] The method the preflight asks for
+@param {string} [path] The request path, base path stripped
+@param {*http.Request} [req] The net/http request instance
+@returns {*CORSOptions, string} The policy, nil when the route has none, and the method of the route
+*/
+func (r *Router) preflightPolicy(method string, path string, req *http.Request) (*CORSOptions, string) {
+	routes, ok := r.routes[method]
+	if !ok {
+		return nil, ""
+	}
+	route, _, match := r.find(routes, path, req)
+	if !match && method == http.MethodHead {
+		route, _, match = r.find(r.routes[http.MethodGet], path, req)
+	}
+	if !match || route.cors == nil {
+		return nil, ""
+	}
+	return route.cors, method
+}
+
+/**
+@info Gets the methods any route is registered for, answering OPTIONS *
+@returns {[]string} Sorted, OPTIONS always included and HEAD whenever GET is
+*/
+func (r *Router) serverMethods() []string {
+	allowed := []string{http.MethodOptions}
+	for method, routes := range r.routes {
+		if len(routes.roots) == 0 || method == http.MethodOptions {
+			continue
+		}
+		allowed = append(allowed, method)
+		if method == http.MethodGet && len(r.routes[http.MethodHead].roots) == 0 {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+/**
+@info Gets a path param of the matched route
+@param {*http.Request} [req] The net/http request instance
+@param {string} [key] The param name
+@returns {string}
+*/
+func (r *Router) GetParam(req *http.Request, key string) string {
+	return Params(req)[key]
+}
@@ -0,0 +1,1879 @@
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func serve(rt *Router, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func write(body string) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}
+}
+
+func TestCatchAllHasLowestPriority(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/*path", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("spa:" + rt.GetParam(r, "path")))
+	})
+	rt.Get("/api/users", write("users"))
+	rt.Get("/api/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user:" + rt.GetParam(r, "id")))
+	})
+	rt.Get("/", write("home"))
+
+	tests := map[string]string{
+		"/":                   "home",
+		"/api/users":          "users",
+		"/api/users/42":       "user:42",
+		"/api/users/42/extra": "spa:api/users/42/extra",
+		"/some/client/route":  "spa:some/client/route",
+		"/settings":           "spa:settings",
+	}
+	for path, want := range tests {
+		if got := serve(rt, "GET", path).Body.String(); got != want {
+			t.Errorf("GET %s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestMaxPathSegments(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/*path", write("ok"))
+
+	path := strings.Repeat("/a", 50000)
+	start := time.Now()
+	w := serve(rt, "GET", path)
+	if w.Code != http.StatusRequestURITooLong {
+		t.Fatalf("pathological path answered %d, want %d", w.Code, http.StatusRequestURITooLong)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("pathological path took %s to reject", elapsed)
+	}
+
+	if w := serve(rt, "GET", strings.Repeat("/a", 100)); w.Code != http.StatusOK {
+		t.Errorf("path at the limit answered %d, want %d", w.Code, http.StatusOK)
+	}
+
+	rt.MaxPathSegments = 0
+	if w := serve(rt, "GET", path); w.Code != http.StatusOK {
+		t.Errorf("disabled guard answered %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestValidateFallsThrough(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users/:id", write("user")).Validate("id", func(v string) bool {
+		for _, c := range v {
+			if c < '0' || c > '9' {
+				return false
+			}
+		}
+		return true
+	})
+	rt.Get("/*path", write("fallback"))
+
+	if got := serve(rt, "GET", "/users/42").Body.String(); got != "user" {
+		t.Errorf("valid id served %q, want %q", got, "user")
+	}
+	if got := serve(rt, "GET", "/users/bob").Body.String(); got != "fallback" {
+		t.Errorf("invalid id served %q, want %q", got, "fallback")
+	}
+}
+
+func TestHandlerConversions(t *testing.T) {
+	var h http.Handler = write("handler")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Body.String(); got != "handler" {
+		t.Errorf("Handler.ServeHTTP wrote %q, want %q", got, "handler")
+	}
+
+	std := http.HandlerFunc(write("std"))
+	back := http.HandlerFunc(FromHTTP(std))
+	w = httptest.NewRecorder()
+	back.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Body.String(); got != "std" {
+		t.Errorf("round-tripped handler wrote %q, want %q", got, "std")
+	}
+
+	rt := NewRouter()
+	rt.Get("/", FromHTTP(std))
+	if got := serve(rt, "GET", "/").Body.String(); got != "std" {
+		t.Errorf("router served %q, want %q", got, "std")
+	}
+}
+
+func TestAllowForParamRoutes(t *testing.T) {
+	rt := NewRouter()
+	rt.Post("/users/:id", write("updated"))
+
+	w := serve(rt, "OPTIONS", "/users/123")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("OPTIONS answered %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if got := w.Header().Get("Allow"); got != "OPTIONS, POST" {
+		t.Errorf("OPTIONS Allow = %q, want %q", got, "OPTIONS, POST")
+	}
+
+	w = serve(rt, "GET", "/users/123")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET answered %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	if got := w.Header().Get("Allow"); got != "OPTIONS, POST" {
+		t.Errorf("GET Allow = %q, want %q", got, "OPTIONS, POST")
+	}
+
+	if w := serve(rt, "OPTIONS", "/posts/123"); w.Code != http.StatusNotFound {
+		t.Errorf("OPTIONS for unknown path answered %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestDeprecatedHeaders(t *testing.T) {
+	sunset := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	rt := NewRouter()
+	rt.Get("/v1/users", write("old")).Deprecated(sunset)
+	rt.Get("/v2/users", write("new"))
+
+	w := serve(rt, "GET", "/v1/users")
+	if got := w.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("Deprecation = %q, want %q", got, "true")
+	}
+	if got, want := w.Header().Get("Sunset"), "Tue, 01 Jan 2030 00:00:00 GMT"; got != want {
+		t.Errorf("Sunset = %q, want %q", got, want)
+	}
+
+	w = serve(rt, "GET", "/v2/users")
+	if got := w.Header().Get("Deprecation"); got != "" {
+		t.Errorf("current route got Deprecation %q", got)
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	rt := NewRouter().SetBasePath("/myapp/")
+	rt.Get("/", write("home"))
+	rt.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user:" + rt.GetParam(r, "id")))
+	})
+
+	tests := map[string]string{
+		"/myapp":          "home",
+		"/myapp/":         "home",
+		"/myapp/users/42": "user:42",
+	}
+	for path, want := range tests {
+		if got := serve(rt, "GET", path).Body.String(); got != want {
+			t.Errorf("GET %s = %q, want %q", path, got, want)
+		}
+	}
+
+	for _, path := range []string{"/", "/users/42", "/myappusers/42"} {
+		if w := serve(rt, "GET", path); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s answered %d, want %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestDefaultResponseFormat(t *testing.T) {
+	rt := NewRouter()
+	rt.Post("/users", write("created"))
+
+	w := serve(rt, "GET", "/missing")
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("text 404 Content-Type = %q", got)
+	}
+
+	rt.DefaultResponseFormat = JSONFormat
+	w = serve(rt, "GET", "/missing")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("JSON 404 answered %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+		t.Errorf("JSON 404 Content-Type = %q", got)
+	}
+	if got, want := w.Body.String(), "{\"error\":\"No matching route found\"}\n"; got != want {
+		t.Errorf("JSON 404 body = %q, want %q", got, want)
+	}
+
+	w = serve(rt, "GET", "/users")
+	if got, want := w.Body.String(), "{\"error\":\"Method not allowed\"}\n"; w.Code != http.StatusMethodNotAllowed || got != want {
+		t.Errorf("JSON 405 = %d %q, want %d %q", w.Code, got, http.StatusMethodNotAllowed, want)
+	}
+}
+
+func TestGreedyParams(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/strict/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("strict:" + rt.GetParam(r, "id")))
+	})
+	rt.Get("/greedy/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("greedy:" + rt.GetParam(r, "id")))
+	}).Greedy()
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/strict/123", http.StatusOK, "strict:123"},
+		{"/strict/123/extra", http.StatusNotFound, ""},
+		{"/greedy/123", http.StatusOK, "greedy:123"},
+		{"/greedy/123/extra", http.StatusOK, "greedy:123/extra"},
+	}
+	for _, tt := range tests {
+		w := serve(rt, "GET", tt.path)
+		if w.Code != tt.code {
+			t.Errorf("GET %s answered %d, want %d", tt.path, w.Code, tt.code)
+			continue
+		}
+		if tt.code == http.StatusOK && w.Body.String() != tt.body {
+			t.Errorf("GET %s = %q, want %q", tt.path, w.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestRoutesListing(t *testing.T) {
+	rt := NewRouter()
+	rt.Post("/users", write(""))
+	rt.Get("/users/:id/posts/:post", write("")).Name("user-post")
+	rt.Get("/users", write("")).Name("users")
+	rt.Get("/", write(""))
+	rt.Get("/files/*path", write(""))
+	rt.Delete("/users/:id", write(""))
+
+	want := []RouteInfo{
+		{Method: "GET", Template: "/"},
+		{Method: "GET", Template: "/files/*path", ParamNames: []string{"path"}},
+		{Method: "GET", Template: "/users", Name: "users"},
+		{Method: "POST", Template: "/users"},
+		{Method: "DELETE", Template: "/users/:id", ParamNames: []string{"id"}},
+		{Method: "GET", Template: "/users/:id/posts/:post", ParamNames: []string{"id", "post"}, Name: "user-post"},
+	}
+	for i := 0; i < 5; i++ {
+		if got := rt.Routes(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Routes() = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestMaxURLLength(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/search", write("ok"))
+	rt.MaxURLLength = 64
+
+	if w := serve(rt, "GET", "/search?q=short"); w.Code != http.StatusOK {
+		t.Errorf("short URL answered %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := serve(rt, "GET", "/search?q="+strings.Repeat("x", 64)); w.Code != http.StatusRequestURITooLong {
+		t.Errorf("long URL answered %d, want %d", w.Code, http.StatusRequestURITooLong)
+	}
+}
+
+func TestMatchFunc(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/x", write("beta")).MatchFunc(func(r *http.Request) bool {
+		return r.Header.Get("X-Channel") == "beta"
+	})
+	rt.Get("/x", write("canary")).MatchFunc(func(r *http.Request) bool {
+		return r.Header.Get("X-Channel") == "canary"
+	})
+	rt.Get("/x", write("stable"))
+
+	for channel, want := range map[string]string{"beta": "beta", "canary": "canary", "": "stable", "other": "stable"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/x", nil)
+		req.Header.Set("X-Channel", channel)
+		rt.ServeHTTP(w, req)
+		if got := w.Body.String(); got != want {
+			t.Errorf("channel %q served %q, want %q", channel, got, want)
+		}
+	}
+}
+
+func TestNotFoundMessage(t *testing.T) {
+	rt := NewRouter().SetNotFoundMessage("Nothing here")
+	rt.Get("/", write("home"))
+
+	w := serve(rt, "GET", "/missing")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing route answered %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if got, want := w.Body.String(), "Nothing here\n"; got != want {
+		t.Errorf("missing route body = %q, want %q", got, want)
+	}
+	if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want %q", got, "nosniff")
+	}
+}
+
+func TestFallback(t *testing.T) {
+	static := NewRouter()
+	static.Get("/app.js", write("static"))
+
+	var ran []string
+	api := NewRouter()
+	api.UseRaw(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ran = append(ran, r.URL.Path)
+			next.ServeHTTP(w, r)
+		})
+	})
+	api.Get("/api/users", write("users"))
+	api.Post("/api/orders", write("order"))
+	api.Fallback(static)
+
+	tests := []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{"GET", "/api/users", http.StatusOK, "users"},
+		{"GET", "/app.js", http.StatusOK, "static"},
+		{"GET", "/api/orders", http.StatusMethodNotAllowed, "Method not allowed\n"},
+		{"GET", "/missing", http.StatusNotFound, "No matching route found\n"},
+	}
+	for _, tt := range tests {
+		w := serve(api, tt.method, tt.path)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+	if want := []string{"/api/users", "/app.js", "/missing"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("middleware ran for %v, want %v", ran, want)
+	}
+}
+
+func TestMatched(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users", func(w http.ResponseWriter, r *http.Request) {
+		if !Matched(r) {
+			t.Error("Matched is false inside the route handler")
+		}
+	})
+
+	var got []bool
+	outer := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = TrackMatch(r)
+			next.ServeHTTP(w, r)
+			got = append(got, Matched(r))
+		})
+	}(rt)
+
+	for _, path := range []string{"/users", "/missing"} {
+		outer.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if want := []bool{true, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Matched = %v, want %v", got, want)
+	}
+	if Matched(httptest.NewRequest("GET", "/users", nil)) {
+		t.Error("Matched is true for a request that never went through the router")
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/logo.png", write("png")).CacheControl("public, max-age=86400")
+	rt.Get("/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+	}).CacheControl("public, max-age=60")
+	rt.Get("/feed", write("feed"))
+
+	tests := map[string]string{
+		"/logo.png": "public, max-age=86400",
+		"/me":       "no-store",
+		"/feed":     "",
+	}
+	for path, want := range tests {
+		if got := serve(rt, "GET", path).Header().Get("Cache-Control"); got != want {
+			t.Errorf("GET %s Cache-Control = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestMountRouteState(t *testing.T) {
+	auth := NewRouter()
+	auth.TrackHits = true
+	auth.Post("/login", write("welcome")).RateLimit(1, time.Minute).Name("login").CacheControl("no-store")
+	rt := NewRouter()
+	rt.TrackHits = true
+	rt.Mount("/auth", auth)
+
+	if w := serve(auth, "POST", "/login"); w.Code != http.StatusOK {
+		t.Fatalf("first login = %d, want 200", w.Code)
+	}
+	if w := serve(auth, "POST", "/login"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second login = %d, want the source route's limit reached", w.Code)
+	}
+	// The copy carries the modifiers but a limiter and hit counter of its own
+	w := serve(rt, "POST", "/auth/login")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("mounted login = %d with Cache-Control %q, want 200 and no-store", w.Code, w.Header().Get("Cache-Control"))
+	}
+	if routes := rt.Routes(); len(routes) != 1 || routes[0].Name != "login" {
+		t.Errorf("mounted routes = %+v, want the login name carried", routes)
+	}
+	if unused := rt.UnusedRoutes(); len(unused) != 0 {
+		t.Errorf("mounted route hits not counted: %+v", unused)
+	}
+}
+
+func TestMountMiddleware(t *testing.T) {
+	var guarded []string
+	guard := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			guarded = append(guarded, r.URL.Path)
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	admin := NewRouter()
+	admin.Get("/", write("dashboard"))
+	admin.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("admin user"))
+	}).CacheControl("no-store")
+
+	rt := NewRouter()
+	rt.Get("/users/:id", write("user"))
+	rt.Mount("/admin", admin, guard)
+
+	tests := []struct{ path, body string }{
+		{"/admin", "dashboard"},
+		{"/admin/users/42", "admin user"},
+		{"/users/42", "user"},
+	}
+	for _, tt := range tests {
+		if got := serve(rt, "GET", tt.path).Body.String(); got != tt.body {
+			t.Errorf("GET %s = %q, want %q", tt.path, got, tt.body)
+		}
+	}
+	if want := []string{"/admin", "/admin/users/42"}; !reflect.DeepEqual(guarded, want) {
+		t.Errorf("mount middleware ran for %v, want %v", guarded, want)
+	}
+	if got := serve(rt, "GET", "/admin/users/42").Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("mounted route lost its modifiers, Cache-Control = %q", got)
+	}
+}
+
+func TestHostRouting(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/", write("api")).Host("API.example.com")
+	rt.Get("/", write("local")).Host("[::1]")
+	rt.Get("/", write("loopback")).Host("127.0.0.1")
+	rt.Get("/", write("default"))
+
+	tests := []struct{ host, body string }{
+		{"api.example.com", "api"},
+		{"api.example.com:8443", "api"},
+		{"[::1]", "local"},
+		{"[::1]:8080", "local"},
+		{"127.0.0.1", "loopback"},
+		{"127.0.0.1:3000", "loopback"},
+		{"example.com", "default"},
+		{"[::2]:8080", "default"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tt.host
+		rt.ServeHTTP(w, req)
+		if got := w.Body.String(); got != tt.body {
+			t.Errorf("host %q served %q, want %q", tt.host, got, tt.body)
+		}
+	}
+}
+
+func benchmarkServe(b *testing.B, rt *Router, path string) {
+	rt.Get("/health", func(w http.ResponseWriter, r *http.Request) {})
+	rt.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", path, nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkServe(b *testing.B) {
+	benchmarkServe(b, NewRouter(), "/users/42")
+}
+
+// Serves a static route end to end, the lookup alone is covered by BenchmarkFindStatic
+func BenchmarkServeStatic(b *testing.B) {
+	benchmarkServe(b, NewRouter(), "/health")
+}
+
+// Compares skipping the empty global middleware chain with running the route
+// handler through it, the way every request went before the chain was skipped
+func BenchmarkRunMiddlewares(b *testing.B) {
+	rt := NewRouter()
+	rt.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+	req, rc := withRouterContext(httptest.NewRequest("GET", "/", nil), rt)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	w := httptest.NewRecorder()
+
+	b.Run("skip empty chain", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rt.runMiddlewares(w, req, rc, next)
+		}
+	})
+	b.Run("always compose", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rc.next = next
+			rt.handler.ServeHTTP(w, req)
+		}
+	})
+}
+
+func TestUseForMethods(t *testing.T) {
+	var ran []string
+	trace := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ran = append(ran, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	rt := NewRouter()
+	rt.UseFor("POST", trace("post"))
+	rt.UseFor("*", trace("all"))
+	rt.UseFor("POST", trace("post2"))
+	rt.Post("/orders", func(w http.ResponseWriter, r *http.Request) {
+		ran = append(ran, "handler")
+	})
+	rt.Get("/orders", func(w http.ResponseWriter, r *http.Request) {
+		ran = append(ran, "handler")
+	})
+
+	serve(rt, "POST", "/orders")
+	if want := []string{"all", "post", "post2", "handler"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("POST ran %v, want %v", ran, want)
+	}
+
+	ran = nil
+	serve(rt, "GET", "/orders")
+	if want := []string{"all", "handler"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("GET ran %v, want %v", ran, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("UseFor accepted an invalid method")
+		}
+	}()
+	rt.UseFor("FETCH", trace("fetch"))
+}
+
+func TestConflictSources(t *testing.T) {
+	rt := NewRouter()
+	rt.Debug = true
+	rt.Register("GET", "/users/:id", http.NotFoundHandler())
+	err := rt.Register("GET", "/users/:name", http.NotFoundHandler())
+	if !errors.Is(err, ErrRouteConflict) {
+		t.Fatalf("Register error = %v, want %v", err, ErrRouteConflict)
+	}
+	if got := strings.Count(err.Error(), "router_test.go:"); got != 2 {
+		t.Errorf("conflict error %q names %d registration sites, want 2", err, got)
+	}
+
+	rt = NewRouter()
+	rt.Register("GET", "/users/:id", http.NotFoundHandler())
+	err = rt.Register("GET", "/users/:name", http.NotFoundHandler())
+	if strings.Contains(err.Error(), ".go:") {
+		t.Errorf("conflict error %q names registration sites without Debug", err)
+	}
+}
+
+func TestMethodNormalization(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users", write("users"))
+
+	for _, method := range []string{"get", "Get", ""} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.Method = method
+		rt.ServeHTTP(w, req)
+		if got := w.Body.String(); got != "users" {
+			t.Errorf("method %q served %d %q, want %q", method, w.Code, got, "users")
+		}
+	}
+
+	rt.StrictMethods = true
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/users", nil)
+	req.Method = "get"
+	rt.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("strict lowercase get answered %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestNoContent(t *testing.T) {
+	rt := NewRouter()
+	rt.Delete("/items/:id", func(w http.ResponseWriter, r *http.Request) {
+		NoContent(w)
+	})
+	rt.Delete("/things/:id", func(w http.ResponseWriter, r *http.Request) {}).Status(http.StatusNoContent)
+	rt.Post("/things", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("created"))
+	}).Status(http.StatusCreated)
+	rt.Put("/things/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}).Status(http.StatusNoContent)
+
+	tests := []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodDelete, "/items/1", http.StatusNoContent},
+		{http.MethodDelete, "/things/1", http.StatusNoContent},
+		{http.MethodPost, "/things", http.StatusCreated},
+		{http.MethodPut, "/things/1", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		w := serve(rt, tt.method, tt.path)
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.code, w.Code)
+		}
+		if tt.code == http.StatusNoContent && w.Body.Len() != 0 {
+			t.Errorf("%s %s: expected empty body, got %q", tt.method, tt.path, w.Body.String())
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	rt := NewRouter()
+	rt.UseRaw(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Old", "1")
+			next.ServeHTTP(w, r)
+		})
+	})
+	rt.Get("/old", write("old"))
+	rt.Get("/same", write("old"))
+
+	rt.Reset()
+	if w := serve(rt, http.MethodGet, "/old"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 after reset, got %d", w.Code)
+	}
+	if len(rt.Routes()) != 0 {
+		t.Errorf("expected no routes after reset, got %v", rt.Routes())
+	}
+
+	rt.Get("/same", write("new"))
+	w := serve(rt, http.MethodGet, "/same")
+	if w.Body.String() != "new" {
+		t.Errorf("expected re-registered route, got %q", w.Body.String())
+	}
+	if w.Header().Get("X-Old") != "" {
+		t.Error("expected middleware to be cleared")
+	}
+}
+
+func TestHeadFallback(t *testing.T) {
+	rt := NewRouter()
+	encoded := false
+	rt.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		JSON(w, http.StatusOK, marshalSpy{&encoded})
+	})
+	rt.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		Text(w, http.StatusOK, "ok")
+	})
+	rt.Post("/items", write("created"))
+
+	w := serve(rt, http.MethodHead, "/users/1")
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("HEAD /users/1 = %d %q, want 200 with no body", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+		t.Errorf("unexpected Content-Type %q", got)
+	}
+	if encoded {
+		t.Error("expected JSON encoding to be skipped for HEAD")
+	}
+
+	w = serve(rt, http.MethodHead, "/health")
+	if w.Body.Len() != 0 || w.Header().Get("Content-Length") != "2" {
+		t.Errorf("HEAD /health = %q with Content-Length %q", w.Body.String(), w.Header().Get("Content-Length"))
+	}
+	if w = serve(rt, http.MethodGet, "/health"); w.Body.String() != "ok" {
+		t.Errorf("GET /health = %q", w.Body.String())
+	}
+
+	if w = serve(rt, http.MethodHead, "/items"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("HEAD /items = %d, want 405", w.Code)
+	}
+	if w = serve(rt, http.MethodPost, "/users/1"); w.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Errorf("unexpected Allow header %q", w.Header().Get("Allow"))
+	}
+}
+
+type marshalSpy struct{ called *bool }
+
+func (m marshalSpy) MarshalJSON() ([]byte, error) {
+	*m.called = true
+	return []byte("{}"), nil
+}
+
+func TestMiddlewareStacks(t *testing.T) {
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Stack", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	rt := NewRouter()
+	rt.Get("/public", write("public"))
+	rt.Get("/me", write("me")).UseStack("authed")
+	rt.Get("/admin", write("admin")).UseStack("authed", "admin")
+	rt.Get("/broken", write("broken")).UseStack("missing")
+	// Stacks resolve at match time so they can be defined after the routes
+	rt.DefineStack("authed", tag("authed"))
+	rt.DefineStack("admin", tag("admin"), tag("audit"))
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/public", nil},
+		{"/me", []string{"authed"}},
+		{"/admin", []string{"authed", "admin", "audit"}},
+	}
+	for _, tt := range tests {
+		w := serve(rt, http.MethodGet, tt.path)
+		if got := w.Header().Values("X-Stack"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected stacks %v, got %v", tt.path, tt.want, got)
+		}
+	}
+	if w := serve(rt, http.MethodGet, "/broken"); w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 for an undefined stack, got %d", w.Code)
+	}
+}
+
+func TestConcurrentRegisterAndServe(t *testing.T) {
+	rt := NewRouter()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				rt.Get(fmt.Sprintf("/r%d/%d", i, j), write("ok"))
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				serve(rt, http.MethodGet, fmt.Sprintf("/r%d/%d", i, j))
+				rt.Routes()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(rt.Routes()); got != 400 {
+		t.Errorf("expected 400 routes, got %d", got)
+	}
+	if w := serve(rt, http.MethodGet, "/r7/49"); w.Body.String() != "ok" {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestConcurrentReconfigureAndServe(t *testing.T) {
+	mw := func(next http.Handler) http.Handler { return next }
+	rt := NewRouter()
+	rt.DefineStack("api", mw)
+	rt.Get("/users/:id", write("user")).UseStack("api")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				rt.Post(fmt.Sprintf("/r%d/%d", i, j), write("ok")).Name(fmt.Sprintf("r%d-%d", i, j)).CacheControl("no-store")
+				rt.UseFor("GET", mw)
+				rt.DefineStack("api", mw)
+				rt.AuditResponses(fmt.Sprintf("r%d-%d", i, j))
+				rt.SetNotFoundMessage("gone")
+				rt.SetBasePath("/")
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if w := serve(rt, http.MethodGet, "/users/1"); w.Body.String() != "user" {
+					t.Errorf("unexpected body %q", w.Body.String())
+				}
+				serve(rt, http.MethodGet, "/missing")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNestedRouterParams(t *testing.T) {
+	var inner, outer map[string]string
+	posts := NewRouter()
+	posts.Get("/posts/:id", func(w http.ResponseWriter, r *http.Request) {
+		inner = Params(r)
+	})
+	rt := NewRouter()
+	rt.Handle("/users/:id", posts)
+	rt.UseFor("*", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			outer = Params(r)
+		})
+	})
+
+	serve(rt, http.MethodGet, "/users/1/posts/2")
+	if want := map[string]string{"id": "2"}; !reflect.DeepEqual(inner, want) {
+		t.Errorf("inner params = %v, want %v", inner, want)
+	}
+	if want := map[string]string{"id": "1", "path": "posts/2"}; !reflect.DeepEqual(outer, want) {
+		t.Errorf("outer params = %v, want %v", outer, want)
+	}
+}
+
+func TestMountPrefixParams(t *testing.T) {
+	posts := NewRouter()
+	posts.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("posts of " + posts.GetParam(r, "uid")))
+	})
+	posts.Get("/posts/:pid", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(posts.GetParam(r, "uid") + "/" + posts.GetParam(r, "pid")))
+	})
+	var seen string
+	rt := NewRouter()
+	rt.Mount("/users/:uid|int", posts, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = posts.GetParam(r, "uid")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	tests := []struct {
+		path, body string
+		code       int
+	}{
+		{"/users/7", "posts of 7", http.StatusOK},
+		{"/users/7/posts/3", "7/3", http.StatusOK},
+		{"/users/ada/posts/3", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := serve(rt, http.MethodGet, tt.path)
+		if w.Code != tt.code || (tt.code == http.StatusOK && w.Body.String() != tt.body) {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+	if seen != "7" {
+		t.Errorf("mount middleware saw uid %q, want 7", seen)
+	}
+}
+
+func TestAuditResponses(t *testing.T) {
+	type entry struct {
+		info RouteInfo
+		body string
+	}
+	var audited []entry
+	rt := NewRouter()
+	rt.AuditSink = func(info RouteInfo, body []byte) {
+		audited = append(audited, entry{info, string(body)})
+	}
+	rt.MaxAuditBytes = 8
+	rt.Post("/payments/:id", write("charged 100 EUR"))
+	rt.Get("/refunds", write("none")).Name("refunds")
+	rt.Get("/health", write("ok"))
+	rt.AuditResponses("/payments/:id", "refunds")
+
+	if w := serve(rt, http.MethodPost, "/payments/7"); w.Body.String() != "charged 100 EUR" {
+		t.Errorf("expected the full body to reach the client, got %q", w.Body.String())
+	}
+	serve(rt, http.MethodGet, "/refunds")
+	serve(rt, http.MethodGet, "/health")
+
+	want := []entry{
+		{RouteInfo{Method: "POST", Template: "/payments/:id", ParamNames: []string{"id"}}, "charged "},
+		{RouteInfo{Method: "GET", Template: "/refunds", Name: "refunds"}, "none"},
+	}
+	if !reflect.DeepEqual(audited, want) {
+		t.Errorf("expected audit entries %+v, got %+v", want, audited)
+	}
+}
+
+func TestRegistrar(t *testing.T) {
+	plugin := func(reg Registrar) error {
+		return reg.Register(http.MethodGet, "/plugin/status", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("plugged"))
+		}))
+	}
+	rt := NewRouter()
+	if err := plugin(rt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := serve(rt, http.MethodGet, "/plugin/status").Body.String(); got != "plugged" {
+		t.Errorf("expected the plugin route, got %q", got)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/search", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %v %q", Query(r, "q"), Query(r, "page"), QueryValues(r, "tag"), Query(r, "other"))
+	}).Queries("q", "page", "tag")
+
+	tests := []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/search?q=go&page=2&tag=a&tag=b&other=x", http.StatusOK, `go 2 [a b] ""`},
+		{"/search?q=go&page=&tag=", http.StatusOK, `go  [] ""`},
+		{"/search?q=go&tag=a", http.StatusBadRequest, `{"error":"Validation failed","fields":[{"in":"query","field":"page","message":"required"}]}` + "\n"},
+		{"/search", http.StatusBadRequest, `{"error":"Validation failed","fields":[{"in":"query","field":"q","message":"required"},{"in":"query","field":"page","message":"required"},{"in":"query","field":"tag","message":"required"}]}` + "\n"},
+	}
+	for _, tt := range tests {
+		w := serve(rt, http.MethodGet, tt.target)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.target, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
+
+func TestQueryDefault(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/posts", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", Query(r, "page"), QueryDefault(r, "sort", "new"))
+	}).QueryDefault("page", "1")
+
+	tests := []struct{ target, body string }{
+		{"/posts", "1 new"},
+		{"/posts?page=", "1 new"},
+		{"/posts?page=3&sort=top", "3 top"},
+	}
+	for _, tt := range tests {
+		if w := serve(rt, http.MethodGet, tt.target); w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Errorf("GET %s = %d %q, want 200 %q", tt.target, w.Code, w.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestHandlePrefix(t *testing.T) {
+	admin := http.NewServeMux()
+	admin.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("dashboard " + r.URL.Path))
+	})
+	admin.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("index " + r.URL.Path))
+	})
+	rt := NewRouter()
+	rt.Handle("/admin", admin)
+	rt.Handle("/tenants/:tid/", admin)
+	rt.Handle("/v1/console", admin)
+	rt.DefaultVersion("v1")
+
+	tests := []struct{ method, path, body string }{
+		{http.MethodGet, "/admin/dashboard", "dashboard /dashboard"},
+		{http.MethodPost, "/admin/dashboard", "dashboard /dashboard"},
+		{http.MethodGet, "/admin", "index /"},
+		{http.MethodGet, "/admin/reports/2024/", "index /reports/2024/"},
+		{http.MethodGet, "/tenants/acme/dashboard", "dashboard /dashboard"},
+		{http.MethodGet, "/v1/console/dashboard", "dashboard /dashboard"},
+		{http.MethodGet, "/console/dashboard", "dashboard /dashboard"},
+		{http.MethodGet, "/console", "index /"},
+	}
+	for _, tt := range tests {
+		if got := serve(rt, tt.method, tt.path).Body.String(); got != tt.body {
+			t.Errorf("%s %s = %q, want %q", tt.method, tt.path, got, tt.body)
+		}
+	}
+}
+
+func TestCanonicalRedirects(t *testing.T) {
+	rt := NewRouter()
+	rt.RedirectTrailingSlash = true
+	rt.RedirectCleanPath = true
+	rt.Get("/users/:id", write("user"))
+	rt.Post("/users", write("created"))
+
+	tests := []struct {
+		code         int
+		method, path string
+		status       int
+		location     string
+	}{
+		{0, http.MethodGet, "/users/1/", http.StatusMovedPermanently, "/users/1"},
+		{0, http.MethodGet, "/users//1?x=1", http.StatusMovedPermanently, "/users/1?x=1"},
+		{0, http.MethodGet, "/users/2/../1", http.StatusMovedPermanently, "/users/1"},
+		{0, http.MethodPost, "/users/", http.StatusPermanentRedirect, "/users"},
+		{http.StatusTemporaryRedirect, http.MethodGet, "/users/1/", http.StatusTemporaryRedirect, "/users/1"},
+		{http.StatusTemporaryRedirect, http.MethodPost, "/users/", http.StatusTemporaryRedirect, "/users"},
+		{http.StatusOK, http.MethodGet, "/users/1/", http.StatusMovedPermanently, "/users/1"},
+		{http.StatusOK, http.MethodPost, "/users/", http.StatusPermanentRedirect, "/users"},
+		{0, http.MethodGet, "/users/1", http.StatusOK, ""},
+		{0, http.MethodGet, "/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rt.RedirectCode = tt.code
+		w := serve(rt, tt.method, tt.path)
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+			t.Errorf("RedirectCode %d: %s %s = %d %q, want %d %q", tt.code, tt.method, tt.path, w.Code, w.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+}
+
+func TestNotModifiedSince(t *testing.T) {
+	modtime := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	rt := NewRouter()
+	rt.Get("/report", func(w http.ResponseWriter, r *http.Request) {
+		if NotModifiedSince(w, r, modtime) {
+			return
+		}
+		w.Write([]byte("report"))
+	})
+
+	tests := []struct {
+		since, etag string
+		code        int
+	}{
+		{"", "", http.StatusOK},
+		{"Wed, 01 May 2024 12:00:00 GMT", "", http.StatusNotModified},
+		{"Wed, 01 May 2024 13:00:00 GMT", "", http.StatusNotModified},
+		{"Wed, 01 May 2024 11:59:59 GMT", "", http.StatusOK},
+		{"not a date", "", http.StatusOK},
+		{"Wed, 01 May 2024 12:00:00 GMT", `"v1"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/report", nil)
+		if tt.since != "" {
+			req.Header.Set("If-Modified-Since", tt.since)
+		}
+		if tt.etag != "" {
+			req.Header.Set("If-None-Match", tt.etag)
+		}
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if w.Code != tt.code || w.Header().Get("Last-Modified") != "Wed, 01 May 2024 12:00:00 GMT" {
+			t.Errorf("If-Modified-Since %q = %d with Last-Modified %q, want %d", tt.since, w.Code, w.Header().Get("Last-Modified"), tt.code)
+		}
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	vary := func(field string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				AddVary(w, field)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	rt := NewRouter()
+	rt.UseRaw(vary("Accept-Encoding"), vary("Accept"), vary("accept-encoding"))
+	rt.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		AddVary(w, "Accept")
+		AddVary(w, "Origin")
+	}).Middleware(Auth(testAuth))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if got, want := w.Header().Values("Vary"), []string{"Accept-Encoding, Accept, Authorization, Origin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected Vary %q, got %q", want, got)
+	}
+
+	w = httptest.NewRecorder()
+	w.Header().Set("Vary", "*")
+	AddVary(w, "Accept")
+	if got := w.Header().Get("Vary"); got != "*" {
+		t.Errorf("expected Vary * to be kept, got %q", got)
+	}
+}
+
+func TestDefaultVersion(t *testing.T) {
+	v1, v2 := NewRouter(), NewRouter()
+	v1.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v1 user " + v1.GetParam(r, "id")))
+	})
+	v1.Post("/users", write("v1 created"))
+	v2.Get("/users/:id", write("v2 user"))
+
+	rt := NewRouter()
+	rt.Get("/health", write("ok"))
+	rt.Mount("/v1", v1)
+	rt.Mount("/v2", v2)
+	rt.DefaultVersion("v1")
+
+	tests := []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{http.MethodGet, "/users/7", http.StatusOK, "v1 user 7"},
+		{http.MethodGet, "/v1/users/7", http.StatusOK, "v1 user 7"},
+		{http.MethodGet, "/v2/users/7", http.StatusOK, "v2 user"},
+		{http.MethodPost, "/users", http.StatusOK, "v1 created"},
+		{http.MethodGet, "/health", http.StatusOK, "ok"},
+		{http.MethodGet, "/v3/users/7", http.StatusNotFound, ""},
+		{http.MethodDelete, "/users/7", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		w := serve(rt, tt.method, tt.path)
+		if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
+
+func TestUnusedRoutes(t *testing.T) {
+	rt := NewRouter()
+	rt.TrackHits = true
+	rt.Get("/users/:id", write("user"))
+	rt.Post("/users", write("created"))
+	rt.Get("/legacy", write("legacy"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(rt, http.MethodGet, "/users/1")
+		}()
+	}
+	wg.Wait()
+	serve(rt, http.MethodHead, "/users/2")
+
+	var unused []string
+	for _, info := range rt.UnusedRoutes() {
+		unused = append(unused, info.Method+" "+info.Template)
+	}
+	if want := []string{"GET /legacy", "POST /users"}; !reflect.DeepEqual(unused, want) {
+		t.Errorf("expected unused routes %v, got %v", want, unused)
+	}
+}
+
+func TestCustomMethodNotAllowed(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/orders", write("orders"))
+	rt.Post("/orders", write("created"))
+	rt.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request, allowed []string) {
+		JSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"method": r.Method, "allowed": allowed})
+	})
+
+	w := serve(rt, http.MethodDelete, "/orders")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", w.Code)
+	}
+	if got, want := w.Header().Get("Allow"), "GET, HEAD, OPTIONS, POST"; got != want {
+		t.Errorf("expected Allow %q, got %q", want, got)
+	}
+	if got, want := w.Body.String(), `{"allowed":["GET","HEAD","OPTIONS","POST"],"method":"DELETE"}`+"\n"; got != want {
+		t.Errorf("expected body %q, got %q", want, got)
+	}
+
+	rt.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request, allowed []string) {})
+	if w := serve(rt, http.MethodDelete, "/orders"); w.Code != http.StatusMethodNotAllowed || w.Body.Len() != 0 {
+		t.Errorf("expected an empty 405, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestUseEncodedPath(t *testing.T) {
+	rt := NewRouter()
+	rt.UseEncodedPath = true
+	rt.Get("/files/:name", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Params(r)["name"]))
+	})
+	rt.Get("/files/:name/meta", write("meta"))
+	rt.Get("/café/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("café " + Params(r)["id"]))
+	})
+	rt.Get("/a b/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a b " + Params(r)["id"]))
+	})
+
+	tests := []struct {
+		path, raw string
+		code      int
+		body      string
+	}{
+		{"/files/a/b", "/files/a%2Fb", http.StatusOK, "a/b"},
+		{"/files/100%/x", "/files/100%25%2Fx", http.StatusOK, "100%/x"},
+		{"/café/1", "/caf%C3%A9/1", http.StatusOK, "café 1"},
+		{"/café/1", "", http.StatusOK, "café 1"},
+		{"/a b/1", "/a%20b/1", http.StatusOK, "a b 1"},
+		{"/a b/x/y", "/a%20b/x%2Fy", http.StatusOK, "a b x/y"},
+		{"/files/a b", "", http.StatusOK, "a b"},
+		{"/files/a/b/meta", "/files/a%2Fb/meta", http.StatusOK, "meta"},
+		{"/files/%zz", "/files/%zz", http.StatusBadRequest, "Malformed path escape\n"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path, req.URL.RawPath = tt.path, tt.raw
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("GET %s (%s) = %d %q, want %d %q", tt.path, tt.raw, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+
+	rt.UseEncodedPath = false
+	if w := serve(rt, http.MethodGet, "/files/a%2Fb"); w.Code != http.StatusNotFound {
+		t.Errorf("expected the decoded path to be matched without UseEncodedPath, got %d", w.Code)
+	}
+}
+
+func TestGlobalHeaders(t *testing.T) {
+	rt := NewRouter()
+	rt.SetHeader("X-Frame-Options", "DENY")
+	rt.SetHeader("X-Build", "1.2.3")
+	rt.ForceHeader("X-Content-Type-Options", "nosniff")
+	rt.Get("/", write("home"))
+	rt.Get("/embed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		w.Header().Set("X-Content-Type-Options", "off")
+		w.Write([]byte("embed"))
+	})
+
+	tests := []struct {
+		path, frame string
+		code        int
+	}{
+		{"/", "DENY", http.StatusOK},
+		{"/missing", "DENY", http.StatusNotFound},
+		{"/embed", "SAMEORIGIN", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := serve(rt, http.MethodGet, tt.path)
+		if w.Code != tt.code {
+			t.Errorf("GET %s: expected %d, got %d", tt.path, tt.code, w.Code)
+		}
+		if got := w.Header().Get("X-Frame-Options"); got != tt.frame {
+			t.Errorf("GET %s: expected X-Frame-Options %q, got %q", tt.path, tt.frame, got)
+		}
+		if got := w.Header().Get("X-Build"); got != "1.2.3" {
+			t.Errorf("GET %s: expected X-Build 1.2.3, got %q", tt.path, got)
+		}
+		if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("GET %s: expected the forced X-Content-Type-Options, got %q", tt.path, got)
+		}
+	}
+}
+
+func TestUseRouterConfigConflicts(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	same := NewRouter()
+	same.Get("/same", write("same"))
+	rt := NewRouter()
+	rt.UseRouter(same)
+	if logs.Len() != 0 {
+		t.Errorf("expected no warnings for matching config, got %q", logs.String())
+	}
+
+	other := NewRouter()
+	other.StrictMethods = true
+	other.SetBasePath("/api")
+	other.UseRaw(func(next http.Handler) http.Handler { return next })
+	other.Get("/other", write("other"))
+	rt.UseRouter(other)
+
+	for _, want := range []string{"StrictMethods is true instead of false", `base path is /api instead of `, "UseRaw middleware"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected a warning containing %q, got %q", want, logs.String())
+		}
+	}
+	// The routes are still merged and served with the core router config
+	if got := serve(rt, "get", "/other").Body.String(); got != "other" {
+		t.Errorf("expected the merged route, got %q", got)
+	}
+}
+
+func TestNormalizeUnicode(t *testing.T) {
+	composed, decomposed := "/caf\u00e9/:id", "/cafe\u0301/:id"
+	rt := NewRouter()
+	rt.NormalizeUnicode = true
+	rt.Get(composed, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("composed " + Params(r)["id"]))
+	})
+	rt.Get("/men\u0303u", write("decomposed"))
+
+	tests := []struct{ path, body string }{
+		{"/caf\u00e9/1", "composed 1"},
+		{"/cafe\u0301/2", "composed 2"},
+		{"/me\u00f1u", "decomposed"},
+		{"/men\u0303u", "decomposed"},
+	}
+	for _, tt := range tests {
+		if got := serve(rt, http.MethodGet, tt.path).Body.String(); got != tt.body {
+			t.Errorf("GET %q = %q, want %q", tt.path, got, tt.body)
+		}
+	}
+
+	plain := NewRouter()
+	plain.Get(composed, write("composed"))
+	if w := serve(plain, http.MethodGet, strings.Replace(decomposed, ":id", "1", 1)); w.Code != http.StatusNotFound {
+		t.Errorf("expected no normalization without the flag, got %d", w.Code)
+	}
+}
+
+func TestPathWithoutLeadingSlash(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + Params(r)["id"]))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.URL.Path = "users/7"
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "/users/7 7" {
+		t.Errorf("GET users/7 = %d %q, want 200 %q", w.Code, w.Body.String(), "/users/7 7")
+	}
+	if req.URL.Path != "users/7" {
+		t.Errorf("the original request path was changed to %q", req.URL.Path)
+	}
+}
+
+func TestDraining(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users", write("users"))
+	rt.Get("/healthz", write("ok")).HealthCheck()
+
+	rt.Draining(true)
+	w := serve(rt, http.MethodGet, "/users")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("GET /users while draining = %d with Retry-After %q, want 503 with one", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve(rt, http.MethodGet, "/healthz"); w.Code != http.StatusOK {
+		t.Errorf("GET /healthz while draining = %d, want 200", w.Code)
+	}
+
+	rt.Draining(false)
+	if w := serve(rt, http.MethodGet, "/users"); w.Code != http.StatusOK {
+		t.Errorf("GET /users after draining = %d, want 200", w.Code)
+	}
+}
+
+func TestOptionsAsterisk(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users", write("")).Post("/users", write("")).Delete("/users/:id", write(""))
+
+	w := serve(rt, http.MethodOptions, "*")
+	if want := "DELETE, GET, HEAD, OPTIONS, POST"; w.Code != http.StatusNoContent || w.Header().Get("Allow") != want {
+		t.Errorf("OPTIONS * = %d with Allow %q, want 204 with %q", w.Code, w.Header().Get("Allow"), want)
+	}
+	if w := serve(NewRouter(), http.MethodOptions, "*"); w.Header().Get("Allow") != "OPTIONS" {
+		t.Errorf("OPTIONS * on an empty router = Allow %q, want OPTIONS", w.Header().Get("Allow"))
+	}
+}
+
+func TestAlias(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/blog/:slug", write("post"))
+	rt.Alias("/old-blog/:slug", "/blog/:slug", http.StatusMovedPermanently)
+	rt.Alias("/archive/:year|int/*rest", "/posts/:year/*rest", 0)
+
+	tests := []struct {
+		target   string
+		location string
+	}{
+		{"/old-blog/hello", "/blog/hello"},
+		{"/old-blog/hello%20world?ref=feed", "/blog/hello%20world?ref=feed"},
+		{"/archive/2020/a/b", "/posts/2020/a/b"},
+	}
+	for _, tt := range tests {
+		w := serve(rt, http.MethodGet, tt.target)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != tt.location {
+			t.Errorf("GET %s = %d to %q, want 301 to %q", tt.target, w.Code, w.Header().Get("Location"), tt.location)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a target param the alias doesn't capture")
+		}
+	}()
+	rt.Alias("/old/:a", "/new/:b", 0)
+}
+
+func TestRouteChainInvalidation(t *testing.T) {
+	tag := func(s string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(s))
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	rt := NewRouter()
+	rt.DefineStack("api", tag("a"))
+	route := rt.Get("/x", write("x")).UseStack("api")
+
+	steps := []struct {
+		change func()
+		want   string
+	}{
+		{func() {}, "ax"},
+		{func() { rt.DefineStack("api", tag("b")) }, "bx"},
+		{func() { rt.UseFor("GET", tag("m")) }, "mbx"},
+		{func() { route.Middleware(tag("r")) }, "mbrx"},
+	}
+	for i, step := range steps {
+		step.change()
+		for j := 0; j < 2; j++ {
+			if got := serve(rt, http.MethodGet, "/x").Body.String(); got != step.want {
+				t.Errorf("step %d, request %d: body = %q, want %q", i, j, got, step.want)
+			}
+		}
+	}
+}
+
+func BenchmarkRouteChain(b *testing.B) {
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { next.ServeHTTP(w, r) })
+	}
+	rt := NewRouter()
+	rt.UseFor("*", mw, mw)
+	rt.UseFor("GET", mw)
+	rt.DefineStack("api", mw, mw, mw)
+	rt.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {}).UseStack("api").Middleware(mw, mw)
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	w := httptest.NewRecorder()
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rt.ServeHTTP(w, req)
+		}
+	})
+	// Composing the chain on every request, like before the cache
+	b.Run("composed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rt.mu.Lock()
+			rt.invalidateChains()
+			rt.mu.Unlock()
+			rt.ServeHTTP(w, req)
+		}
+	})
+}
+
+func TestWhen(t *testing.T) {
+	rt := NewRouter()
+	rt.When(true).Get("/debug", write("debug")).Name("debug")
+	rt.When(false).Get("/dev", write("dev")).Post("/dev", write("dev"))
+
+	if w := serve(rt, http.MethodGet, "/debug"); w.Code != http.StatusOK {
+		t.Errorf("GET /debug = %d, want 200", w.Code)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if w := serve(rt, method, "/dev"); w.Code != http.StatusNotFound {
+			t.Errorf("%s /dev = %d, want 404", method, w.Code)
+		}
+	}
+}
+
+func TestMaxHeaderBytes(t *testing.T) {
+	rt := NewRouter()
+	rt.MaxHeaderBytes = 1024
+	rt.Get("/", write("ok"))
+
+	tests := []struct {
+		value string
+		code  int
+	}{
+		{strings.Repeat("a", 100), http.StatusOK},
+		{strings.Repeat("a", 2000), http.StatusRequestHeaderFieldsTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Big", tt.value)
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("header of %d bytes = %d, want %d", len(tt.value), w.Code, tt.code)
+		}
+	}
+}
+
+func TestMatchTemplate(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/health", write(""))
+	rt.Get("/users/:id|int", write(""))
+	rt.Post("/users/:id/posts/*rest", write(""))
+
+	tests := []struct {
+		method, path, template string
+		ok                     bool
+	}{
+		{http.MethodGet, "/health", "/health", true},
+		{http.MethodGet, "/users/42", "/users/:id|int", true},
+		{http.MethodHead, "/users/42", "/users/:id|int", true},
+		{http.MethodPost, "/users/42/posts/a/b", "/users/:id/posts/*rest", true},
+		{http.MethodGet, "/users/abc", "", false},
+		{http.MethodDelete, "/health", "", false},
+		{http.MethodGet, "/missing", "", false},
+	}
+	for _, tt := range tests {
+		if template, ok := rt.MatchTemplate(tt.method, tt.path); template != tt.template || ok != tt.ok {
+			t.Errorf("MatchTemplate(%s, %s) = %q, %v, want %q, %v", tt.method, tt.path, template, ok, tt.template, tt.ok)
+		}
+	}
+}
+
+func TestRouteTags(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users", write("")).Tag("public", "v1")
+	rt.Post("/users", write("")).Tag("v1")
+	rt.Get("/admin", write("")).Tag("internal").Tag("internal")
+
+	if got, want := rt.Routes("v1"), []RouteInfo{
+		{Method: "GET", Template: "/users", Tags: []string{"public", "v1"}},
+		{Method: "POST", Template: "/users", Tags: []string{"v1"}},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Routes(v1) = %+v, want %+v", got, want)
+	}
+	if got := rt.Routes("public", "v1"); len(got) != 1 || got[0].Method != "GET" {
+		t.Errorf("Routes(public, v1) = %+v, want the GET route", got)
+	}
+	if got := rt.Routes(); len(got) != 3 || !reflect.DeepEqual(got[0].Tags, []string{"internal"}) {
+		t.Errorf("Routes() = %+v, want every route with its tags", got)
+	}
+
+	var walked []string
+	rt.Walk(func(method, template string, handler http.Handler) error {
+		walked = append(walked, method+" "+template)
+		return nil
+	}, "internal")
+	if want := []string{"GET /admin"}; !reflect.DeepEqual(walked, want) {
+		t.Errorf("Walk(internal) = %v, want %v", walked, want)
+	}
+}
+
+func TestWalkRegisters(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/reports", write("reports")).Tag("export")
+	rt.Get("/users", write("users"))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rt.Walk(func(method, template string, handler http.Handler) error {
+			rt.Get(template+"/copy", handler.ServeHTTP).Tag("copy").CacheControl("no-store")
+			return nil
+		}, "export")
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("registering from a Walk callback deadlocked")
+	}
+
+	w := serve(rt, http.MethodGet, "/reports/copy")
+	if w.Body.String() != "reports" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("GET /reports/copy = %q with Cache-Control %q", w.Body.String(), w.Header().Get("Cache-Control"))
+	}
+	if got := rt.Routes("copy"); len(got) != 1 || got[0].Template != "/reports/copy" {
+		t.Errorf("Routes(copy) = %+v, want only /reports/copy", got)
+	}
+}
+
+func TestConstrainedParamFallsThroughToWildcard(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/files/*path", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("path:" + rt.GetParam(r, "path")))
+	})
+	rt.Get("/files/:id|int", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("id:" + rt.GetParam(r, "id")))
+	})
+	rt.Get("/docs/:name", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("greedy:" + rt.GetParam(r, "name")))
+	}).Greedy()
+	rt.Get("/docs/:id|int/raw", write("raw"))
+	rt.Get("/items/:name", write("name"))
+	rt.Get("/items/:id|int", write("id"))
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/files/42", "id:42"},
+		{"/files/readme", "path:readme"},
+		{"/files/42/readme", "path:42/readme"},
+		{"/docs/7/raw", "raw"},
+		{"/docs/intro/raw", "greedy:intro/raw"},
+		{"/items/42", "id"},
+		{"/items/bob", "name"},
+	}
+	for _, tt := range tests {
+		if got := serve(rt, "GET", tt.path).Body.String(); got != tt.body {
+			t.Errorf("GET %s = %q, want %q", tt.path, got, tt.body)
+		}
+	}
+}
+
+func TestDisableMIMESniffing(t *testing.T) {
+	rt := NewRouter()
+	rt.DisableMIMESniffing = true
+	rt.DefaultResponseFormat = JSONFormat
+	rt.Get("/json", func(w http.ResponseWriter, r *http.Request) {
+		JSON(w, http.StatusOK, map[string]string{"a": "b"})
+	})
+	rt.Get("/text", func(w http.ResponseWriter, r *http.Request) {
+		Text(w, http.StatusOK, "<html>not html</html>")
+	})
+
+	tests := []struct {
+		path        string
+		contentType string
+	}{
+		{"/json", "application/json; charset=utf-8"},
+		{"/text", "text/plain; charset=utf-8"},
+		{"/missing", "application/json; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		w := serve(rt, "GET", tt.path)
+		if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("GET %s: X-Content-Type-Options = %q, want nosniff", tt.path, got)
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("GET %s: Content-Type = %q, want %q", tt.path, got, tt.contentType)
+		}
+	}
+
+	rt.DisableMIMESniffing = false
+	if got := serve(rt, "GET", "/text").Header().Get("X-Content-Type-Options"); got != "" {
+		t.Errorf("X-Content-Type-Options = %q with sniffing allowed", got)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	rt := NewRouter()
+	rt.DefineStack("auth", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Auth", "checked")
+			next.ServeHTTP(w, r)
+		})
+	})
+	users := rt.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user:" + rt.GetParam(r, "id")))
+	}).UseStack("auth")
+	rt.Freeze()
+
+	if err := rt.Register("GET", "/late", write("late")); !errors.Is(err, ErrRouterFrozen) {
+		t.Errorf("Register after Freeze returned %v, want ErrRouterFrozen", err)
+	}
+	route, _ := NewRoute("GET", []Segment{{Kind: StaticSegment, Name: "late"}}, write("late"))
+	if _, err := rt.AddRoute(route); !errors.Is(err, ErrRouterFrozen) {
+		t.Errorf("AddRoute after Freeze returned %v, want ErrRouterFrozen", err)
+	}
+	rejected := map[string]func(){
+		"Get":         func() { rt.Get("/late", write("late")) },
+		"UseRaw":      func() { rt.UseRaw(func(h http.Handler) http.Handler { return h }) },
+		"UseFor":      func() { rt.UseFor("GET", func(h http.Handler) http.Handler { return h }) },
+		"DefineStack": func() { rt.DefineStack("late") },
+		"Mount":       func() { rt.Mount("/sub", NewRouter()) },
+		"modifier":    func() { users.Name("user") },
+	}
+	for name, fn := range rejected {
+		func() {
+			defer func() {
+				if p := recover(); p == nil || !strings.Contains(fmt.Sprint(p), "router frozen") {
+					t.Errorf("%s after Freeze recovered %v, want a router frozen panic", name, p)
+				}
+			}()
+			fn()
+		}()
+	}
+
+	w := serve(rt, "GET", "/users/42")
+	if w.Body.String() != "user:42" || w.Header().Get("X-Auth") != "checked" {
+		t.Errorf("frozen router served %q with X-Auth %q", w.Body.String(), w.Header().Get("X-Auth"))
+	}
+	if w := serve(rt, "GET", "/late"); w.Code != http.StatusNotFound {
+		t.Errorf("rejected route answered %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	rt.Reset()
+	rt.Get("/late", write("late"))
+	if got := serve(rt, "GET", "/late").Body.String(); got != "late" {
+		t.Errorf("route registered after Reset served %q", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/data", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data as " + Format(r)))
+	}).Format("json", "xml")
+	rt.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "user %s as %s", rt.GetParam(r, "id"), Format(r))
+	}).Format("json", "xml")
+	rt.Get("/files/*path", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "file %s %q", rt.GetParam(r, "path"), Format(r))
+	})
+	// Without Format the route must not see the path with its extension stripped
+	rt.Get("/plain/a/:x", write("plain a/x"))
+	rt.Get("/plain/a", write("plain a"))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/data.json", http.StatusOK, "data as json"},
+		{"/data.xml", http.StatusOK, "data as xml"},
+		{"/data.csv", http.StatusNotFound, ""},
+		{"/data", http.StatusNotFound, ""},
+		{"/users/42.json", http.StatusOK, "user 42 as json"},
+		{"/users/42.xml/", http.StatusOK, "user 42 as xml"},
+		{"/users/42", http.StatusNotFound, ""},
+		{"/files/report.json", http.StatusOK, `file report.json ""`},
+		{"/plain/a.json", http.StatusNotFound, ""},
+		{"/plain/a", http.StatusOK, "plain a"},
+	}
+	for _, tt := range tests {
+		w := serve(rt, "GET", tt.path)
+		if w.Code != tt.code {
+			t.Errorf("GET %s answered %d, want %d", tt.path, w.Code, tt.code)
+			continue
+		}
+		if tt.code == http.StatusOK && w.Body.String() != tt.body {
+			t.Errorf("GET %s = %q, want %q", tt.path, w.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestHandlerHooks(t *testing.T) {
+	rt := NewRouter()
+	var calls []string
+	rt.OnBeforeHandler(func(r *http.Request) {
+		calls = append(calls, "before1 "+r.URL.Path+" "+RouteTemplate(r))
+	})
+	rt.OnBeforeHandler(func(r *http.Request) {
+		calls = append(calls, "before2")
+	})
+	rt.OnAfterHandler(func(r *http.Request, status int) {
+		calls = append(calls, fmt.Sprintf("after %s %d", r.URL.Path, status))
+	})
+	rt.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+		w.Write([]byte("user"))
+	})
+	rt.Post("/users", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		want   []string
+	}{
+		{"GET", "/users/1", []string{"before1 /users/1 /users/:id", "before2", "handler", "after /users/1 200"}},
+		{"POST", "/users", []string{"before1 /users /users", "before2", "handler", "after /users 201"}},
+		{"GET", "/missing", []string{"before1 /missing ", "before2", "after /missing 404"}},
+	}
+	for _, tt := range tests {
+		calls = nil
+		serve(rt, tt.method, tt.path)
+		if !reflect.DeepEqual(calls, tt.want) {
+			t.Errorf("%s %s ran %q, want %q", tt.method, tt.path, calls, tt.want)
+		}
+	}
+}
+
+func TestJSONContentLength(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/small", func(w http.ResponseWriter, r *http.Request) {
+		JSON(w, http.StatusCreated, map[string]int{"id": 7})
+	})
+	rt.Get("/broken", func(w http.ResponseWriter, r *http.Request) {
+		if err := JSON(w, http.StatusOK, make(chan int)); err != nil {
+			Text(w, http.StatusInternalServerError, "encoding failed")
+		}
+	})
+
+	w := serve(rt, "GET", "/small")
+	if w.Code != http.StatusCreated || w.Body.String() != "{\"id\":7}\n" {
+		t.Errorf("GET /small = %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Length"); got != "9" {
+		t.Errorf("Content-Length = %q, want 9", got)
+	}
+
+	if w := serve(rt, "GET", "/broken"); w.Code != http.StatusInternalServerError || w.Body.String() != "encoding failed" {
+		t.Errorf("failed encoding answered %d %q, want the handler's own error", w.Code, w.Body.String())
+	}
+
+	large := strings.Repeat("x", maxBufferedJSON)
+	rt.Get("/large", func(w http.ResponseWriter, r *http.Request) {
+		JSON(w, http.StatusAccepted, map[string]string{"data": large})
+	})
+	w = serve(rt, "GET", "/large")
+	if got := w.Header().Get("Content-Length"); got != "" {
+		t.Errorf("streamed body sent Content-Length %q", got)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); w.Code != http.StatusAccepted || err != nil || body["data"] != large {
+		t.Errorf("GET /large = %d, decoding %v", w.Code, err)
+	}
+}
+
+func TestOnError(t *testing.T) {
+	rt := NewRouter()
+	var errs []string
+	rt.OnError(func(r *http.Request, status int) {
+		errs = append(errs, fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, status))
+	})
+	rt.Get("/ok", write("ok"))
+	rt.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	rt.Get("/q", write("q")).Queries("term")
+	rt.Get("/s", write("s")).UseStack("undefined")
+	rt.MaxPathSegments = 3
+
+	serve(rt, "GET", "/ok")
+	if errs != nil {
+		t.Errorf("a 200 fired the error hook with %q", errs)
+	}
+	serve(rt, "GET", "/fail")
+	serve(rt, "POST", "/ok")
+	serve(rt, "GET", "/missing")
+	serve(rt, "GET", "/q")
+	serve(rt, "GET", "/s")
+	serve(rt, "GET", "/a/b/c/d")
+	want := []string{"GET /fail 500", "POST /ok 405", "GET /missing 404", "GET /q 400", "GET /s 500", "GET /a/b/c/d 414"}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("error hook saw %q, want %q", errs, want)
+	}
+}
@@ -0,0 +1,95 @@
+package mux
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// The kinds of path segment a Segment describes
+type SegmentKind int
+
+const (
+	// Matches its Name literally
+	StaticSegment SegmentKind = iota
+	// Matches any single segment, or the ones of its Type, under Name
+	ParamSegment
+	// Matches the rest of the path under Name, it has to be the last segment
+	WildcardSegment
+)
+
+/**
+ * @info A path segment for building routes without parsing a path string
+ * @property {SegmentKind} [Kind] Whether the segment is static text, a param or a wildcard
+ * @property {string} [Name] The static text, or the param name
+ * @property {string} [Type] The shorthand param type, like int or uuid, empty matches any value
+ */
+type Segment struct {
+	Kind SegmentKind
+	Name string
+	Type string
+}
+
+/**
+@info Builds a route from its segments, for generated routers. It matches exactly like the
+route registered with the equivalent path string and is registered with AddRoute. Its modifiers are set
+before AddRoute, afterwards through the RouteBuilder it returns
+@param {string} [method] The route method
+@param {[]Segment} [segments] The path segments, none for the root path
+@param {http.Handler} [h] The handler for the route
+@returns {*Route, error}
+*/
+func NewRoute(method string, segments []Segment, h http.Handler) (*Route, error) {
+	route := &Route{function: h, method: method}
+	var rootParts []string
+	seen := make(map[string]bool)
+	for i, s := range segments {
+		if s.Kind == StaticSegment {
+			if s.Name == "" || strings.Contains(s.Name, "/") {
+				return nil, fmt.Errorf("%w: static segment %q must be non-empty and hold no /", ErrInvalidPath, s.Name)
+			}
+			if len(route.partNames) == 0 {
+				rootParts = append(rootParts, s.Name)
+			} else {
+				route.partNames = append(route.partNames, param{name: s.Name, fixed: true})
+			}
+			continue
+		}
+
+		if s.Kind != ParamSegment && s.Kind != WildcardSegment {
+			return nil, fmt.Errorf("%w: unknown segment kind %d", ErrInvalidPath, s.Kind)
+		}
+		if s.Kind == WildcardSegment && i != len(segments)-1 {
+			return nil, fmt.Errorf("%w: wildcard %q must be the last segment", ErrInvalidPath, s.Name)
+		}
+		if s.Name == "" {
+			return nil, fmt.Errorf("%w: unnamed param", ErrInvalidPath)
+		}
+		if !validParamName(s.Name) {
+			return nil, fmt.Errorf("%w: param name %q can only hold letters, digits, _ and -", ErrInvalidPath, s.Name)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("%w %s", ErrDuplicateParam, s.Name)
+		}
+		seen[s.Name] = true
+
+		var matcher *regexp.Regexp
+		if s.Type != "" {
+			var ok bool
+			if matcher, ok = paramTypes[s.Type]; !ok || s.Kind == WildcardSegment {
+				return nil, fmt.Errorf("%w: unknown param type %q for %s", ErrInvalidPath, s.Type, s.Name)
+			}
+		}
+		route.partNames = append(route.partNames, param{
+			name:     s.Name,
+			wildcard: s.Kind == WildcardSegment,
+			kind:     s.Type,
+			matcher:  matcher,
+		})
+	}
+	if len(rootParts) > 0 {
+		route.prefix = "/" + strings.Join(rootParts, "/")
+	}
+	return route, nil
+}
@@ -0,0 +1,86 @@
+package mux
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestNewRoute(t *testing.T) {
+	byString, bySegments := NewRouter(), NewRouter()
+	show := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, Params(r))
+	}
+	byString.Get("/users/:id|int/posts/*rest", show)
+	route, err := NewRoute("GET", []Segment{
+		{Kind: StaticSegment, Name: "users"},
+		{Kind: ParamSegment, Name: "id", Type: "int"},
+		{Kind: StaticSegment, Name: "posts"},
+		{Kind: WildcardSegment, Name: "rest"},
+	}, http.HandlerFunc(show))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bySegments.AddRoute(route); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/users/7/posts/a/b", "/users/x/posts/a", "/users/7/posts", "/users/7/other/a", "/users"} {
+		want, got := serve(byString, http.MethodGet, path), serve(bySegments, http.MethodGet, path)
+		if got.Code != want.Code || got.Body.String() != want.Body.String() {
+			t.Errorf("GET %s = %d %q, want %d %q", path, got.Code, got.Body.String(), want.Code, want.Body.String())
+		}
+	}
+
+	if _, err := byString.AddRoute(route); !errors.Is(err, ErrRouteConflict) {
+		t.Errorf("AddRoute conflicting route = %v, want ErrRouteConflict", err)
+	}
+}
+
+func TestNewRouteErrors(t *testing.T) {
+	tests := []struct {
+		segments []Segment
+		err      error
+	}{
+		{[]Segment{{Kind: StaticSegment, Name: "a/b"}}, ErrInvalidPath},
+		{[]Segment{{Kind: WildcardSegment, Name: "rest"}, {Kind: StaticSegment, Name: "a"}}, ErrInvalidPath},
+		{[]Segment{{Kind: ParamSegment, Name: "id", Type: "float"}}, ErrInvalidPath},
+		{[]Segment{{Kind: ParamSegment, Name: "id"}, {Kind: ParamSegment, Name: "id"}}, ErrDuplicateParam},
+	}
+	for _, tt := range tests {
+		if _, err := NewRoute("GET", tt.segments, nil); !errors.Is(err, tt.err) {
+			t.Errorf("NewRoute(%+v) = %v, want %v", tt.segments, err, tt.err)
+		}
+	}
+
+	route, _ := NewRoute("BREW", nil, nil)
+	if _, err := NewRouter().AddRoute(route); !errors.Is(err, ErrInvalidMethod) {
+		t.Errorf("AddRoute with method BREW = %v, want ErrInvalidMethod", err)
+	}
+}
+
+func TestAddRouteModifiedAfterServing(t *testing.T) {
+	rt := NewRouter()
+	route, err := NewRoute("GET", []Segment{{Kind: StaticSegment, Name: "report"}}, write("report"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := rt.AddRoute(route.Name("report"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(rt, http.MethodGet, "/report"); w.Header().Get("X-Late") != "" {
+		t.Fatalf("unexpected X-Late header before the modifier, got %q", w.Header().Get("X-Late"))
+	}
+
+	b.Middleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Late", "yes")
+			next.ServeHTTP(w, r)
+		})
+	})
+	if w := serve(rt, http.MethodGet, "/report"); w.Header().Get("X-Late") != "yes" || w.Body.String() != "report" {
+		t.Errorf("middleware added after serving wasn't applied, got %q %q", w.Header().Get("X-Late"), w.Body.String())
+	}
+}
@@ -0,0 +1,105 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// The largest request body mirrored, bigger requests only reach the primary handler
+	maxShadowBody = 1 << 20
+	// The shadow requests in flight at once, requests past that aren't mirrored
+	maxShadowRequests = 32
+	// How long a shadow request may take
+	shadowTimeout = 10 * time.Second
+)
+
+/**
+@info Creates a middleware mirroring a sample of the requests to a shadow backend, for testing a rollout
+on real traffic. The copies are sent in the background and their responses ignored, so the client
+response is never delayed or changed. Requests are dropped from the mirror rather than queued when too
+many copies are in flight, or when the body is over 1MB
+@param {string} [targetURL] The shadow backend base URL, the request path and query are appended to it
+@param {float64} [sampleRate] The share of requests to mirror, from 0 to 1
+@returns {func(http.Handler) http.Handler}
+*/
+func Shadow(targetURL string, sampleRate float64) func(http.Handler) http.Handler {
+	target, err := url.Parse(targetURL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		panic("Minima: Shadow needs an absolute target URL, got " + targetURL)
+	}
+	client := &http.Client{Timeout: shadowTimeout}
+	slots := make(chan struct{}, maxShadowRequests)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rand.Float64() >= sampleRate {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var body []byte
+			if r.Body != nil && r.Body != http.NoBody {
+				read, err := io.ReadAll(io.LimitReader(r.Body, maxShadowBody+1))
+				// Put the bytes read back in front of the rest for the primary handler
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(read), r.Body), r.Body}
+				if err != nil || len(read) > maxShadowBody {
+					next.ServeHTTP(w, r)
+					return
+				}
+				body = read
+			}
+
+			select {
+			case slots <- struct{}{}:
+				shadow, err := shadowRequest(target, r, body)
+				if err != nil {
+					<-slots
+					log.Printf("Minima: Skipping shadow request: %s", err)
+					break
+				}
+				go func() {
+					defer func() { <-slots }()
+					res, err := client.Do(shadow)
+					if err != nil {
+						return
+					}
+					io.Copy(io.Discard, res.Body)
+					res.Body.Close()
+				}()
+			default:
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+/**
+@info Builds the copy of a request for the shadow backend, detached from the client request context
+@param {*url.URL} [target] The shadow backend base URL
+@param {*http.Request} [r] The net/http request instance
+@param {[]byte} [body] The buffered request body
+@returns {*http.Request, error}
+*/
+func shadowRequest(target *url.URL, r *http.Request, body []byte) (*http.Request, error) {
+	u := *target
+	u.Path = strings.TrimSuffix(u.Path, "/") + r.URL.Path
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+	shadow, err := http.NewRequestWithContext(context.Background(), r.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	shadow.Header = r.Header.Clone()
+	shadow.Header.Del("Connection")
+	return shadow, nil
+}
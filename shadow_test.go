@@ -0,0 +1,58 @@
+package mux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShadow(t *testing.T) {
+	type copy struct{ method, target, body, header string }
+	received := make(chan copy, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- copy{r.Method, r.URL.RequestURI(), string(body), r.Header.Get("X-Id")}
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("shadow"))
+	}))
+	defer backend.Close()
+
+	rt := NewRouter()
+	rt.UseRaw(Shadow(backend.URL+"/mirror", 1))
+	rt.Post("/orders", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("primary " + string(body)))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/orders?dry=1", strings.NewReader(`{"id":1}`))
+	req.Header.Set("X-Id", "42")
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || w.Body.String() != `primary {"id":1}` {
+		t.Errorf("primary response = %d %q, want 201 %q", w.Code, w.Body.String(), `primary {"id":1}`)
+	}
+
+	want := copy{http.MethodPost, "/mirror/orders?dry=1", `{"id":1}`, "42"}
+	select {
+	case got := <-received:
+		if got != want {
+			t.Errorf("shadow received %+v, want %+v", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the shadow backend never received the copy")
+	}
+
+	rt = NewRouter()
+	rt.UseRaw(Shadow(backend.URL, 0))
+	rt.Get("/orders", write("primary"))
+	serve(rt, http.MethodGet, "/orders")
+	select {
+	case got := <-received:
+		t.Errorf("expected no copy with a 0 sample rate, got %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
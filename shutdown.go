@@ -0,0 +1,56 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+)
+
+/**
+@info Sets the parent context of every request the router serves, cancelling it or calling Shutdown
+cancels the request contexts too so streaming handlers watching r.Context().Done() return
+@param {context.Context} [ctx] The parent context
+*/
+func (r *Router) BaseContext(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancelBase != nil {
+		r.cancelBase()
+	}
+	r.base, r.cancelBase = context.WithCancel(ctx)
+}
+
+/**
+@info Shuts a server using the router down gracefully. The router starts draining, the base context
+set with BaseContext is cancelled so streaming and long polling handlers stop, then the server waits
+for the requests still running. Handlers that don't watch their context just finish normally
+@param {context.Context} [ctx] The context bounding how long to wait
+@param {*http.Server} [srv] The server to shut down
+@returns {error} The error of srv.Shutdown
+*/
+func (r *Router) Shutdown(ctx context.Context, srv *http.Server) error {
+	r.Draining(true)
+	r.mu.RLock()
+	cancel := r.cancelBase
+	r.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+	return srv.Shutdown(ctx)
+}
+
+/**
+@info Derives the request context so it's also cancelled with the base context
+@param {*http.Request} [req] The net/http request instance
+@param {context.Context} [base] The base context
+@returns {*http.Request, context.CancelFunc} The cancel function releases the watch once the request is served
+*/
+func withBaseContext(req *http.Request, base context.Context) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(req.Context())
+	// The base context calls cancel itself when it's done, there's no
+	// goroutine waiting on it for every request
+	stop := context.AfterFunc(base, cancel)
+	return req.WithContext(ctx), func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,72 @@
+package mux
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShutdownStopsStreams(t *testing.T) {
+	rt := NewRouter()
+	rt.BaseContext(context.Background())
+	done := make(chan struct{})
+	rt.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		stream, err := SSE(w)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for {
+			stream.Send("tick", "1")
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+	ts := httptest.NewServer(rt)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if _, err := bufio.NewReader(res.Body).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := rt.Shutdown(ctx, ts.Config); err != nil {
+		t.Errorf("Shutdown = %v, want nil", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("the streaming handler kept running after Shutdown")
+	}
+}
+
+func TestBaseContextCancel(t *testing.T) {
+	rt := NewRouter()
+	ctx, cancel := context.WithCancel(context.Background())
+	rt.BaseContext(ctx)
+	rt.Get("/wait", func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		select {
+		case <-r.Context().Done():
+			w.Write([]byte("cancelled"))
+		case <-time.After(time.Second):
+			w.Write([]byte("timeout"))
+		}
+	})
+	if got := serve(rt, http.MethodGet, "/wait").Body.String(); got != "cancelled" {
+		t.Errorf("body = %q, want cancelled", got)
+	}
+}
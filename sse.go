@@ -0,0 +1,60 @@
+package mux
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/**
+ * @info The server-sent events stream
+ * @property {http.ResponseWriter} [w] The net/http response instance, flushed after every event
+ */
+type SSEStream struct {
+	w http.ResponseWriter
+}
+
+/**
+@info Starts a server-sent events stream on the response. The handler should keep sending until
+r.Context() is done, which BaseContext and Shutdown use to end streams on shutdown
+@param {http.ResponseWriter} [w] The net/http response instance, it must support flushing
+@returns {*SSEStream, error} http.ErrNotSupported when the response instance can't flush
+*/
+func SSE(w http.ResponseWriter) (*SSEStream, error) {
+	if !canFlush(w) {
+		return nil, http.ErrNotSupported
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := flush(w); err != nil {
+		return nil, err
+	}
+
+	return &SSEStream{w: w}, nil
+}
+
+/**
+@info Sends an event to the client and flushes it straight away
+@param {string} [event] The event name, empty sends an unnamed message event
+@param {string} [data] The event data, multiple lines are sent as multiple data fields
+@returns {error}
+*/
+func (s *SSEStream) Send(event, data string) error {
+	var b strings.Builder
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+
+	if _, err := s.w.Write([]byte(b.String())); err != nil {
+		return err
+	}
+	return flush(s.w)
+}
@@ -0,0 +1,72 @@
+package mux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+func TestSSE(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+		stream, err := SSE(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream.Send("greeting", "hello\nworld")
+		stream.Send("", "bye")
+	})
+
+	w := serve(rt, "GET", "/events")
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want %q", got, "text/event-stream")
+	}
+	if got := w.Header().Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("Cache-Control = %q, want %q", got, "no-cache")
+	}
+	if !w.Flushed {
+		t.Error("stream was never flushed")
+	}
+	want := "event: greeting\ndata: hello\ndata: world\n\ndata: bye\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+
+	if _, err := SSE(plainWriter{httptest.NewRecorder()}); err == nil {
+		t.Error("SSE accepted a writer without flushing support")
+	}
+}
+
+func TestSSEThroughWrappers(t *testing.T) {
+	var sseErr error
+	rt := NewRouter()
+	rt.AuditSink = func(RouteInfo, []byte) {}
+	rt.ForceHeader("X-Frame-Options", "DENY")
+	rt.OnAfterHandler(func(*http.Request, int) {})
+	rt.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+		if _, sseErr = SSE(w); sseErr != nil {
+			http.Error(w, "no streaming", http.StatusInternalServerError)
+		}
+	})
+	rt.AuditResponses("/events")
+
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(plainWriter{rec}, httptest.NewRequest("GET", "/events", nil))
+	if !errors.Is(sseErr, http.ErrNotSupported) {
+		t.Errorf("SSE error = %v, want http.ErrNotSupported", sseErr)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/events", nil))
+	if !rec.Flushed || sseErr != nil {
+		t.Errorf("flushing writer: flushed %v, SSE error %v", rec.Flushed, sseErr)
+	}
+}
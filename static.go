@@ -0,0 +1,102 @@
+package mux
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+/**
+ * @info The options of Static
+ * @property {bool} [Browse] Lists the files of directories without an index.html instead of answering 404
+ */
+type StaticOptions struct {
+	Browse bool
+}
+
+/**
+@info Serves the files of a file system under a prefix for GET and HEAD requests. Directories are served
+through their index.html, and without one they answer 404 unless browsing is enabled
+@param {string} [prefix] The path prefix, like /static
+@param {http.FileSystem} [root] The file system to serve, like http.Dir("public")
+@param {...StaticOptions} [opts] The options, browsing is disabled by default
+@returns {*Router}
+*/
+func (r *Router) Static(prefix string, root http.FileSystem, opts ...StaticOptions) *Router {
+	var o StaticOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	h := http.FileServer(root)
+	if !o.Browse {
+		h = r.noBrowse(root, h)
+	}
+	return r.handlePrefix([]string{http.MethodGet, http.MethodHead}, prefix, h)
+}
+
+/**
+@info Wraps a file server so directories without an index.html answer 404 instead of being listed
+@param {http.FileSystem} [root] The file system served
+@param {http.Handler} [h] The file server
+@returns {http.Handler}
+*/
+func (r *Router) noBrowse(root http.FileSystem, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := path.Clean("/" + req.URL.Path)
+		if f, err := root.Open(name); err == nil {
+			info, err := f.Stat()
+			f.Close()
+			if err == nil && info.IsDir() {
+				index, err := root.Open(path.Join(name, "index.html"))
+				if err != nil {
+					r.notFound(w)
+					return
+				}
+				index.Close()
+			}
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+/**
+@info Serves a single page app, answering with its index file every GET request no other route matches so
+the client side router takes over. Paths under an API prefix, and paths whose last segment has a file
+extension like /app.js, answer 404 instead so missing endpoints and assets aren't masked by the index
+@param {string} [indexFile] The path of the index file on disk
+@param {[]string} [apiPrefixes] The path prefixes of the API, like /api
+@returns {*RouteBuilder}
+*/
+func (r *Router) SPA(indexFile string, apiPrefixes []string) *RouteBuilder {
+	return r.Get("/*path", func(w http.ResponseWriter, req *http.Request) {
+		p, _ := r.stripBasePath(req.URL.Path)
+		for _, prefix := range apiPrefixes {
+			prefix = strings.TrimSuffix(prefix, "/")
+			if p == prefix || strings.HasPrefix(p, prefix+"/") {
+				r.spaNotFound(w, req)
+				return
+			}
+		}
+		if path.Ext(p) != "" {
+			r.spaNotFound(w, req)
+			return
+		}
+		http.ServeFile(w, req, indexFile)
+	})
+}
+
+/**
+@info Answers a path the single page app doesn't serve like an unmatched request, through the fallback when there's one
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {*http.Request} [req] The net/http request instance
+*/
+func (r *Router) spaNotFound(w http.ResponseWriter, req *http.Request) {
+	r.mu.RLock()
+	fallback := r.fallback
+	r.mu.RUnlock()
+	if fallback == nil {
+		r.notFound(w)
+		return
+	}
+	fallback.ServeHTTP(w, req)
+}
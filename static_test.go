@@ -0,0 +1,79 @@
+package mux
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatic(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "docs"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "assets"), 0o755)
+	os.WriteFile(filepath.Join(dir, "docs", "index.html"), []byte("docs index"), 0o644)
+	os.WriteFile(filepath.Join(dir, "assets", "app.css"), []byte("body{}"), 0o644)
+
+	tests := []struct {
+		browse bool
+		path   string
+		code   int
+		body   string
+	}{
+		{false, "/static/assets/app.css", http.StatusOK, "body{}"},
+		{false, "/static/docs/", http.StatusOK, "docs index"},
+		{false, "/static/assets/", http.StatusNotFound, ""},
+		{false, "/static/", http.StatusNotFound, ""},
+		{true, "/static/docs/", http.StatusOK, "docs index"},
+		{true, "/static/assets/", http.StatusOK, "app.css"},
+		{true, "/static/", http.StatusOK, "assets/"},
+	}
+	for _, tt := range tests {
+		rt := NewRouter()
+		rt.Static("/static", http.Dir(dir), StaticOptions{Browse: tt.browse})
+		w := serve(rt, http.MethodGet, tt.path)
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("browse %v: GET %s = %d %q, want %d containing %q", tt.browse, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+
+	rt := NewRouter()
+	rt.Static("/static", http.Dir(dir))
+	if w := serve(rt, http.MethodPost, "/static/assets/app.css"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected POST to be rejected, got %d", w.Code)
+	}
+}
+
+func TestSPA(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "assets"), 0o755)
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>app</html>"), 0o644)
+	os.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("run()"), 0o644)
+
+	rt := NewRouter()
+	rt.Get("/api/users", write("users"))
+	rt.Static("/assets", http.Dir(filepath.Join(dir, "assets")))
+	rt.SPA(filepath.Join(dir, "index.html"), []string{"/api/"})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/", http.StatusOK, "<html>app</html>"},
+		{"/some/client/route", http.StatusOK, "<html>app</html>"},
+		{"/api/users", http.StatusOK, "users"},
+		{"/api/x", http.StatusNotFound, ""},
+		{"/api", http.StatusNotFound, ""},
+		{"/assets/app.js", http.StatusOK, "run()"},
+		{"/assets/missing.js", http.StatusNotFound, ""},
+		{"/favicon.ico", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := serve(rt, http.MethodGet, tt.path)
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("GET %s = %d %q, want %d containing %q", tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package mux
+
+import (
+	"io"
+	"net/http/httptest"
+)
+
+/**
+@info Serves a request built from the arguments through the router, for one line route tests
+@param {string} [method] The request method
+@param {string} [target] The request path, query included
+@param {io.Reader} [body] The request body, nil for none
+@returns {*httptest.ResponseRecorder} The recorded response
+*/
+func (r *Router) TestRequest(method, target string, body io.Reader) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, body))
+	return w
+}
+
+/**
+@info Starts an httptest server serving the router, the caller has to Close it
+@returns {*httptest.Server}
+*/
+func (r *Router) TestServer() *httptest.Server {
+	return httptest.NewServer(r)
+}
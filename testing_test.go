@@ -0,0 +1,40 @@
+package mux
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func echoBody(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Write(body)
+}
+
+func TestTestRequest(t *testing.T) {
+	rt := NewRouter()
+	rt.Post("/echo", echoBody)
+	if w := rt.TestRequest(http.MethodPost, "/echo", strings.NewReader("hi")); w.Code != http.StatusOK || w.Body.String() != "hi" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if w := rt.TestRequest(http.MethodGet, "/missing", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestTestServer(t *testing.T) {
+	rt := NewRouter()
+	rt.Post("/echo", echoBody)
+	srv := rt.TestServer()
+	defer srv.Close()
+
+	res, err := srv.Client().Post(srv.URL+"/echo", "text/plain", strings.NewReader("hi"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer res.Body.Close()
+	if body, _ := io.ReadAll(res.Body); string(body) != "hi" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
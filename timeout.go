@@ -0,0 +1,32 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+/**
+@info Creates a middleware giving the request context a deadline, handlers observe it through
+r.Context().Done() and stop working once it fires
+@param {time.Duration} [d] How long the handlers get
+@returns {func(http.Handler) http.Handler}
+*/
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+/**
+@info Gets the deadline of the request context, set by Timeout or the server
+@param {*http.Request} [r] The net/http request instance
+@returns {time.Time, bool} false when the request has no deadline
+*/
+func Deadline(r *http.Request) (time.Time, bool) {
+	return r.Context().Deadline()
+}
@@ -0,0 +1,77 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	var deadline bool
+	var err error
+	rt := NewRouter()
+	rt.UseRaw(Timeout(10 * time.Millisecond))
+	rt.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		_, deadline = Deadline(r)
+		select {
+		case <-r.Context().Done():
+			err = r.Context().Err()
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case <-time.After(time.Second):
+			w.Write([]byte("finished"))
+		}
+	})
+
+	w := rt.TestRequest(http.MethodGet, "/slow", nil)
+	if !deadline {
+		t.Error("expected the handler to see a deadline")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the handler to observe the timeout, got %v and %d", err, w.Code)
+	}
+}
+
+func TestMiddlewareRequestReachesHandler(t *testing.T) {
+	type key struct{}
+	rt := NewRouter()
+	rt.UseRaw(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), key{}, "set")))
+		})
+	})
+	rt.Get("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		v, _ := r.Context().Value(key{}).(string)
+		w.Write([]byte(v + " " + Params(r)["id"]))
+	})
+	rt.Fallback(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, _ := r.Context().Value(key{}).(string)
+		w.Write([]byte("fallback " + v))
+	}))
+
+	if got := rt.TestRequest(http.MethodGet, "/users/7", nil).Body.String(); got != "set 7" {
+		t.Errorf("expected the handler to get the middleware request, got %q", got)
+	}
+	if got := rt.TestRequest(http.MethodGet, "/missing", nil).Body.String(); got != "fallback set" {
+		t.Errorf("expected the fallback to get the middleware request, got %q", got)
+	}
+	if _, ok := Deadline(httptest.NewRequest(http.MethodGet, "/", nil)); ok {
+		t.Error("expected no deadline without Timeout")
+	}
+}
+
+func TestMiddlewareCanStopHandler(t *testing.T) {
+	ran := false
+	rt := NewRouter()
+	rt.UseRaw(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	})
+	rt.Get("/", func(w http.ResponseWriter, r *http.Request) { ran = true })
+	if w := rt.TestRequest(http.MethodGet, "/", nil); w.Code != http.StatusForbidden || ran {
+		t.Errorf("expected the middleware to stop the handler, got %d and ran %v", w.Code, ran)
+	}
+}
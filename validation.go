@@ -0,0 +1,121 @@
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+/**
+ * @info One failed field of a ValidationError
+ * @property {string} [In] Where the field comes from, param, query or body
+ * @property {string} [Field] The field name, empty when the whole body is rejected
+ * @property {string} [Message] What is wrong with the value
+ */
+type FieldError struct {
+	In      string `json:"in"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+/**
+ * @info The error of the param, query and body validation, aggregating every failed field so clients
+ * get all of them at once in the same shape
+ * @property {[]FieldError} [Fields] The failed fields in the order they were checked
+ */
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+/**
+@info Records a failed field
+@param {string} [in] Where the field comes from, param, query or body
+@param {string} [field] The field name
+@param {string} [message] What is wrong with the value
+*/
+func (e *ValidationError) Add(in string, field string, message string) {
+	e.Fields = append(e.Fields, FieldError{In: in, Field: field, Message: message})
+}
+
+/**
+@info Gets the error when any field failed
+@returns {error} nil when no field was added, so it can be returned directly
+*/
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+/**
+@info Lists the failed fields, like `validation failed: query q: required; body age: expected int`
+@returns {string}
+*/
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.In
+		if f.Field != "" {
+			parts[i] += " " + f.Field
+		}
+		parts[i] += ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+/**
+@info The default validation error renderer, answering 400 with
+{"error": "Validation failed", "fields": [{"in": ..., "field": ..., "message": ...}]}
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {*http.Request} [r] The net/http request instance
+@param {*ValidationError} [err] The failed fields
+*/
+func ValidationJSON(w http.ResponseWriter, r *http.Request, err *ValidationError) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	JSON(w, http.StatusBadRequest, struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}{"Validation failed", err.Fields})
+}
+
+/**
+@info Writes a validation error with the ValidationRenderer of the router serving the request, or
+ValidationJSON when it has none. Errors that aren't a ValidationError answer a plain 400
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {*http.Request} [r] The net/http request instance
+@param {error} [err] The error of DecodeParams, RequireParams or a body decoder
+*/
+func WriteValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	render := ValidationJSON
+	if rc := getRouteContext(r); rc != nil && rc.owner != nil && rc.owner.ValidationRenderer != nil {
+		render = rc.owner.ValidationRenderer
+	}
+	render(w, r, verr)
+}
+
+/**
+@info Turns a JSON decoding error into a ValidationError on the body
+@param {error} [err] The decoding error
+@returns {*ValidationError}
+*/
+func bodyValidationError(err error) *ValidationError {
+	verr := &ValidationError{}
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		verr.Add("body", typeErr.Field, "expected "+typeErr.Type.String())
+	case errors.Is(err, io.EOF):
+		verr.Add("body", "", "required")
+	default:
+		verr.Add("body", "", "malformed JSON")
+	}
+	return verr
+}
@@ -0,0 +1,80 @@
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestValidationErrorAggregatesFields(t *testing.T) {
+	type page struct {
+		Org   string `param:"org"`
+		Page  int    `param:"page"`
+		Limit uint8  `param:"limit"`
+	}
+	rt := NewRouter()
+	rt.Get("/orgs/:org/pages/:page/:limit", func(w http.ResponseWriter, r *http.Request) {
+		var p page
+		if err := DecodeParams(r, &p); err != nil {
+			WriteValidationError(w, r, err)
+			return
+		}
+		fmt.Fprintf(w, "%s %d %d", p.Org, p.Page, p.Limit)
+	})
+
+	if got := serve(rt, "GET", "/orgs/acme/pages/2/10").Body.String(); got != "acme 2 10" {
+		t.Errorf("valid params served %q", got)
+	}
+
+	w := serve(rt, "GET", "/orgs/acme/pages/two/1000")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid params answered %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	var body struct {
+		Error  string
+		Fields []FieldError
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldError{
+		{In: "param", Field: "page", Message: "expected int"},
+		{In: "param", Field: "limit", Message: "expected uint8"},
+	}
+	if body.Error != "Validation failed" || !reflect.DeepEqual(body.Fields, want) {
+		t.Errorf("body = %+v, want the fields %+v", body, want)
+	}
+}
+
+func TestValidationRenderer(t *testing.T) {
+	rt := NewRouter()
+	var got *ValidationError
+	rt.ValidationRenderer = func(w http.ResponseWriter, r *http.Request, err *ValidationError) {
+		got = err
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	rt.Get("/search", write("ok")).Queries("q", "page")
+	rt.Get("/teams/:team", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := RequireParams(r, "team", "member"); err != nil {
+			WriteValidationError(w, r, err)
+		}
+	})
+	rt.Get("/plain", func(w http.ResponseWriter, r *http.Request) {
+		WriteValidationError(w, r, errors.New("bad input"))
+	})
+
+	if w := serve(rt, "GET", "/search?page=1"); w.Code != http.StatusUnprocessableEntity || got == nil || got.Error() != "validation failed: query q: required" {
+		t.Errorf("missing query = %d %v", w.Code, got)
+	}
+	got = nil
+	if w := serve(rt, "GET", "/teams/core"); w.Code != http.StatusUnprocessableEntity || got == nil || got.Error() != "validation failed: param member: required" {
+		t.Errorf("missing param = %d %v", w.Code, got)
+	}
+	got = nil
+	if w := serve(rt, "GET", "/plain"); w.Code != http.StatusBadRequest || w.Body.String() != "bad input\n" || got != nil {
+		t.Errorf("plain error = %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,364 @@
+package mux
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+)
+
+/**
+ * @info The response writer wrapper the router hands to handlers that need to see what was written
+ * @property {http.ResponseWriter} [ResponseWriter] The wrapped net/http response instance
+ * @property {int} [status] The status code written, 0 until the header is sent
+ * @property {int} [defaultStatus] The status sent on first write when WriteHeader wasn't called
+ * @property {int64} [written] The body bytes written
+ */
+type responseWriter struct {
+	http.ResponseWriter
+	status        int
+	defaultStatus int
+	written       int64
+}
+
+/**
+@info Wraps a net/http response instance
+@param {http.ResponseWriter} [w] The net/http response instance
+@param {int} [defaultStatus] The status sent when the handler doesn't set one
+@returns {*responseWriter}
+*/
+func newResponseWriter(w http.ResponseWriter, defaultStatus int) *responseWriter {
+	if defaultStatus == 0 {
+		defaultStatus = http.StatusOK
+	}
+	return &responseWriter{ResponseWriter: w, defaultStatus: defaultStatus}
+}
+
+/**
+@info Sends the response header with the given status, only the first call has an effect
+@param {int} [status] The response status code
+*/
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+/**
+@info Writes the response body, sending the default status first if no header was sent yet
+@param {[]byte} [b] The body bytes
+@returns {int, error}
+*/
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(w.defaultStatus)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+/**
+@info Sends the default status if the handler returned without writing anything
+*/
+func (w *responseWriter) finish() {
+	if w.status == 0 {
+		w.WriteHeader(w.defaultStatus)
+	}
+}
+
+/**
+@info Flushes the wrapped response instance when it supports it
+*/
+func (w *responseWriter) Flush() {
+	w.FlushError()
+}
+
+/**
+@info Flushes the wrapped response instance, sending the default status first if no header was sent yet
+@returns {error} http.ErrNotSupported when the wrapped response instance can't flush
+*/
+func (w *responseWriter) FlushError() error {
+	if !canFlush(w.ResponseWriter) {
+		return http.ErrNotSupported
+	}
+	if w.status == 0 {
+		w.WriteHeader(w.defaultStatus)
+	}
+	return flush(w.ResponseWriter)
+}
+
+/**
+@info Reports whether the wrapped response instance can flush
+@returns {bool}
+*/
+func (w *responseWriter) canFlush() bool {
+	return canFlush(w.ResponseWriter)
+}
+
+/**
+@info Hijacks the wrapped response instance connection when it supports it
+@returns {net.Conn, *bufio.ReadWriter, error}
+*/
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+/**
+@info Gets the wrapped response instance, used by Push and http.ResponseController
+@returns {http.ResponseWriter}
+*/
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// The response writer wrapper discarding the body when a GET route answers a HEAD request
+type headWriter struct {
+	http.ResponseWriter
+}
+
+/**
+@info Discards the response body
+@param {[]byte} [b] The body bytes
+@returns {int, error}
+*/
+func (w *headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+/**
+@info Gets the wrapped response instance
+@returns {http.ResponseWriter}
+*/
+func (w *headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+/**
+@info Reports whether the response body is discarded because a GET route answers a HEAD request
+@param {http.ResponseWriter} [w] The net/http response instance
+@returns {bool}
+*/
+func isHead(w http.ResponseWriter) bool {
+	for {
+		if _, ok := w.(*headWriter); ok {
+			return true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = u.Unwrap()
+	}
+}
+
+// The response writer wrapper copying the body for the audit sink, up to a limit
+type auditWriter struct {
+	http.ResponseWriter
+	body  bytes.Buffer
+	limit int
+}
+
+/**
+@info Writes the response body, keeping a copy until the limit is reached
+@param {[]byte} [b] The body bytes
+@returns {int, error}
+*/
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if room := w.limit - w.body.Len(); room > 0 {
+		if len(b) > room {
+			w.body.Write(b[:room])
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+/**
+@info Flushes the wrapped response instance when it supports it
+*/
+func (w *auditWriter) Flush() {
+	w.FlushError()
+}
+
+/**
+@info Flushes the wrapped response instance
+@returns {error} http.ErrNotSupported when the wrapped response instance can't flush
+*/
+func (w *auditWriter) FlushError() error {
+	return flush(w.ResponseWriter)
+}
+
+/**
+@info Reports whether the wrapped response instance can flush
+@returns {bool}
+*/
+func (w *auditWriter) canFlush() bool {
+	return canFlush(w.ResponseWriter)
+}
+
+/**
+@info Gets the wrapped response instance
+@returns {http.ResponseWriter}
+*/
+func (w *auditWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// The response writer wrapper setting the router's forced headers right before the header is sent
+type forcedHeaderWriter struct {
+	http.ResponseWriter
+	headers http.Header
+	sent    bool
+}
+
+/**
+@info Sends the response header with the forced headers in place
+@param {int} [status] The response status code
+*/
+func (w *forcedHeaderWriter) WriteHeader(status int) {
+	if !w.sent {
+		w.sent = true
+		for key, values := range w.headers {
+			w.Header()[key] = append([]string(nil), values...)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+/**
+@info Writes the response body, sending the header first if needed
+@param {[]byte} [b] The body bytes
+@returns {int, error}
+*/
+func (w *forcedHeaderWriter) Write(b []byte) (int, error) {
+	if !w.sent {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+/**
+@info Flushes the wrapped response instance when it supports it
+*/
+func (w *forcedHeaderWriter) Flush() {
+	w.FlushError()
+}
+
+/**
+@info Flushes the wrapped response instance, sending the header first if needed
+@returns {error} http.ErrNotSupported when the wrapped response instance can't flush
+*/
+func (w *forcedHeaderWriter) FlushError() error {
+	if !canFlush(w.ResponseWriter) {
+		return http.ErrNotSupported
+	}
+	if !w.sent {
+		w.WriteHeader(http.StatusOK)
+	}
+	return flush(w.ResponseWriter)
+}
+
+/**
+@info Reports whether the wrapped response instance can flush
+@returns {bool}
+*/
+func (w *forcedHeaderWriter) canFlush() bool {
+	return canFlush(w.ResponseWriter)
+}
+
+/**
+@info Hijacks the wrapped response instance connection when it supports it
+@returns {net.Conn, *bufio.ReadWriter, error}
+*/
+func (w *forcedHeaderWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+/**
+@info Gets the wrapped response instance
+@returns {http.ResponseWriter}
+*/
+func (w *forcedHeaderWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// The wrappers in this package expose Flush whatever they wrap, they report
+// through canFlush whether a flush would reach the client
+type flushForwarder interface {
+	canFlush() bool
+}
+
+/**
+@info Reports whether a response instance can flush, looking through the wrappers
+@param {http.ResponseWriter} [w] The net/http response instance
+@returns {bool}
+*/
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		switch f := w.(type) {
+		case flushForwarder:
+			return f.canFlush()
+		case interface{ FlushError() error }, http.Flusher:
+			return true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = u.Unwrap()
+	}
+}
+
+/**
+@info Flushes a response instance, looking through wrappers following the http.ResponseController convention
+@param {http.ResponseWriter} [w] The net/http response instance
+@returns {error} http.ErrNotSupported when nothing in the chain can flush
+*/
+func flush(w http.ResponseWriter) error {
+	for {
+		switch f := w.(type) {
+		case interface{ FlushError() error }:
+			return f.FlushError()
+		case http.Flusher:
+			f.Flush()
+			return nil
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return http.ErrNotSupported
+		}
+		w = u.Unwrap()
+	}
+}
+
+/**
+@info Wraps a handler so responses default to the given status
+@param {int} [status] The default status code
+@param {http.Handler} [h] The handler to wrap
+@returns {http.Handler}
+*/
+func withStatus(status int, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseWriter(w, status)
+		h.ServeHTTP(rw, r)
+		rw.finish()
+	})
+}
+
+/**
+@info Sends an empty 204 No Content response
+@param {http.ResponseWriter} [w] The net/http response instance
+*/
+func NoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}